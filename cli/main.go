@@ -1,48 +1,198 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"github.com/nspcc-dev/neo-go/pkg/chain"
 	"github.com/nspcc-dev/neo-go/pkg/core/block"
 	"github.com/nspcc-dev/neo-go/pkg/core/state"
 	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
 	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neo-go/pkg/encoding/address"
 	"github.com/nspcc-dev/neo-go/pkg/io"
+	"github.com/nspcc-dev/neo-go/pkg/rpc"
 	"github.com/nspcc-dev/neo-go/pkg/smartcontract"
 	"github.com/nspcc-dev/neo-go/pkg/smartcontract/trigger"
 	"github.com/nspcc-dev/neo-go/pkg/util"
 	"github.com/nspcc-dev/neo-go/pkg/vm"
+	"github.com/nspcc-dev/neo-go/pkg/vm/emit"
 	"github.com/nspcc-dev/neo-go/pkg/vm/opcode"
 	"log"
 	"math"
-	"math/rand"
+	"math/big"
 	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
+	setup()
+	if len(serve) > 0 {
+		log.Fatalln(serveRPC(serve))
+	}
+	if len(batch) > 0 {
+		err := runBatch(batch, workers)
+		saveRecording()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+	req := &invokeRequest{
+		Script:    hexscript,
+		Witnesses: strings.Split(wits, ":"),
+		GasLimit:  gaslimit,
+		Trigger:   trigname,
+		Tx:        hextx,
+	}
+	if pinheight >= 0 {
+		h := uint32(pinheight)
+		req.Height = &h
+	}
+	if blocktime >= 0 {
+		t := uint32(blocktime)
+		req.Time = &t
+	}
+	if len(contract) > 0 {
+		params, err := parseParameters(jsonparams, flag.Args())
+		if err != nil {
+			exitWithError(newInvocationError(err, errorKindRequest))
+		}
+		fn := &invokeFunctionRequest{
+			Contract:  contract,
+			Operation: operation,
+			Params:    params,
+		}
+		script, err := fn.buildScript()
+		if err != nil {
+			exitWithError(newInvocationError(err, errorKindRequest))
+		}
+		log.Println("[SCRIPT]", hex.EncodeToString(script))
+		req.Script = hex.EncodeToString(script)
+	}
+	inv, err := newInvocation(req)
+	if err != nil {
+		saveRecording()
+		exitWithError(newInvocationError(err, errorKindRequest))
+	}
+	var result map[string]interface{}
+	if verify {
+		result, err = inv.verifyWitnesses()
+	} else {
+		result, err = inv.run()
+	}
+	saveRecording()
+	if err != nil {
+		exitWithError(newInvocationError(err, errorKindFault))
+	}
+	res, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(string(res))
+}
+
+// exitWithError prints the error object and exits.
+func exitWithError(e *invocationError) {
+	res, err := json.Marshal(map[string]interface{}{"error": e})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(string(res))
+	os.Exit(1)
+}
+
+// newInvocationError describes the error, kind is used unless it's a backend
+// failure.
+func newInvocationError(err error, kind string) *invocationError {
+	res := &invocationError{Kind: kind, Message: err.Error()}
+	var se *vm.SyscallError
+	if errors.As(err, &se) {
+		res.Syscall = se.Name
+	}
+	var be *chain.Error
+	if errors.As(err, &be) {
+		res.Kind = errorKindBackend
+		res.Lookup = be.Method
+	}
+	return res
+}
+
+// run runs the invocation script and returns the result object.
+func (inv *invocation) run() (map[string]interface{}, error) {
+	nvm := inv.newVM()
+	nvm.SetGasLimit(util.Fixed8(inv.gaslimit))
+	nvm.LoadScript(inv.script)
+	runErr := nvm.Run()
+	// Nothing is changed by a faulted script.
+	storageChanges := []contractStorageChanges{}
+	transfers, balanceChanges := []nep5Transfer{}, []nep5BalanceChange{}
+	if runErr == nil {
+		var err error
+		storageChanges, err = inv.getStorageChanges()
+		if err != nil {
+			return nil, err
+		}
+		transfers, balanceChanges = getNEP5Transfers(inv.notifications, inv.height)
+	}
+	aer := &state.AppExecResult{
+		Trigger:     inv.trig,
+		VMState:     nvm.State(),
+		GasConsumed: nvm.GasConsumed(),
+		Stack:       nvm.Estack().ToContractParameters(),
+		Events:      inv.notifications,
+	}
+	if inv.container != nil {
+		aer.TxHash = inv.container.Hash()
+	}
+	result := map[string]interface{}{
+		"script":               hex.EncodeToString(inv.script),
+		"state":                nvm.State(),
+		"gas_consumed":         nvm.GasConsumed(),
+		"stack":                aer.Stack,
+		"storage_changes":      storageChanges,
+		"logs":                 inv.logs,
+		"application_log":      aer,
+		"nep5_transfers":       transfers,
+		"nep5_balance_changes": balanceChanges,
+	}
+	if runErr != nil {
+		result["error"] = newInvocationError(runErr, errorKindFault)
+	}
+	if isVerification(inv.trig) {
+		result["verified"] = isVerified(nvm)
+	}
+	buf := io.NewBufBinWriter()
+	aer.EncodeBinary(buf.BinWriter)
+	if buf.Err != nil {
+		log.Println("[APPLOG]", buf.Err)
+	} else {
+		result["application_log_binary"] = hex.EncodeToString(buf.Bytes())
+	}
+	return result, nil
+}
+
+// newVM returns a new VM with all interops registered.
+func (inv *invocation) newVM() *vm.VM {
 	nvm := vm.New()
 	nvm.SetPriceGetter(getPrice)
-	nvm.SetScriptGetter(func(hash util.Uint160) ([]byte, bool) {
-		data := map[string]interface{}{
-			"jsonrpc": "2.0",
-			"id":      rand.Uint32(),
-			"method":  "GetContractByContractHashBlockHeightInHex",
-			"params":  map[string]interface{}{"ContractHash": hash.StringBE(), "BlockHeight": height},
-		}
-		log.Println("[REQ]", data)
-		resp := mERR(http.Post(rpcaddr, "application/json", bytes.NewReader(mERR(json.Marshal(data)).([]byte)))).(*http.Response)
-		defer resp.Body.Close()
-		mCHK(json.NewDecoder(resp.Body).Decode(&data))
-		log.Println("[RESP]", data)
-		cs := new(state.Contract)
-		cs.DecodeBinary(io.NewBinReaderFromBuf(mERR(hex.DecodeString(data["result"].(string))).([]byte)[1:]))
+	nvm.SetScriptGetter(func(hash util.Uint160) ([]byte, bool, error) {
+		cs, err := inv.getContract(hash)
+		if err != nil {
+			return nil, false, err
+		}
 		log.Println("[CONTRACT]", hash)
-		return cs.Script, (cs.Properties & smartcontract.HasDynamicInvoke) != 0
+		return cs.Script, cs.HasDynamicInvoke(), nil
 	})
 
 	nvm.RegisterInteropGetter(func(id uint32) *vm.InteropFuncPrice {
@@ -90,20 +240,14 @@ func main() {
 			log.Println("[SYSCALL]", "System.Blockchain.GetBlock")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					hash := mERR(getBlockHashFromElement(v.Estack().Pop())).(util.Uint256)
-					data := map[string]interface{}{
-						"jsonrpc": "2.0",
-						"id":      rand.Uint32(),
-						"method":  "GetBlockByBlockHashInHex",
-						"params":  map[string]interface{}{"BlockHash": hash.StringBE()},
-					}
-					log.Println("[REQ]", data)
-					resp := mERR(http.Post(rpcaddr, "application/json", bytes.NewReader(mERR(json.Marshal(data)).([]byte)))).(*http.Response)
-					defer resp.Body.Close()
-					mCHK(json.NewDecoder(resp.Body).Decode(&data))
-					log.Println("[RESP]", data)
-					blk := new(block.Block)
-					blk.DecodeBinary(io.NewBinReaderFromBuf(mERR(hex.DecodeString(data["result"].(string))).([]byte)))
+					hash, err := inv.getBlockHashFromElement(v.Estack().Pop())
+					if err != nil {
+						return err
+					}
+					blk, err := inv.getBlock(hash)
+					if err != nil {
+						return err
+					}
 					v.Estack().PushVal(vm.NewInteropItem(blk))
 					return nil
 				},
@@ -113,20 +257,14 @@ func main() {
 			log.Println("[SYSCALL]", "System.Blockchain.GetContract")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					hash := mERR(util.Uint160DecodeBytesBE(v.Estack().Pop().Bytes())).(util.Uint160)
-					data := map[string]interface{}{
-						"jsonrpc": "2.0",
-						"id":      rand.Uint32(),
-						"method":  "GetContractByContractHashBlockHeightInHex",
-						"params":  map[string]interface{}{"ContractHash": hash.StringBE(), "BlockHeight": height},
-					}
-					log.Println("[REQ]", data)
-					resp := mERR(http.Post(rpcaddr, "application/json", bytes.NewReader(mERR(json.Marshal(data)).([]byte)))).(*http.Response)
-					defer resp.Body.Close()
-					mCHK(json.NewDecoder(resp.Body).Decode(&data))
-					log.Println("[RESP]", data)
-					cs := new(state.Contract)
-					cs.DecodeBinary(io.NewBinReaderFromBuf(mERR(hex.DecodeString(data["result"].(string))).([]byte)[1:]))
+					hash, err := util.Uint160DecodeBytesBE(v.Estack().Pop().Bytes())
+					if err != nil {
+						return err
+					}
+					cs, err := inv.getContract(hash)
+					if err != nil {
+						return err
+					}
 					v.Estack().PushVal(vm.NewInteropItem(cs))
 					return nil
 				},
@@ -136,20 +274,14 @@ func main() {
 			log.Println("[SYSCALL]", "System.Blockchain.GetHeader")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					hash := mERR(getBlockHashFromElement(v.Estack().Pop())).(util.Uint256)
-					data := map[string]interface{}{
-						"jsonrpc": "2.0",
-						"id":      rand.Uint32(),
-						"method":  "GetHeaderByBlockHashInHex",
-						"params":  map[string]interface{}{"BlockHash": hash.StringBE()},
-					}
-					log.Println("[REQ]", data)
-					resp := mERR(http.Post(rpcaddr, "application/json", bytes.NewReader(mERR(json.Marshal(data)).([]byte)))).(*http.Response)
-					defer resp.Body.Close()
-					mCHK(json.NewDecoder(resp.Body).Decode(&data))
-					log.Println("[RESP]", data)
-					hd := new(block.Header)
-					hd.DecodeBinary(io.NewBinReaderFromBuf(mERR(hex.DecodeString(data["result"].(string))).([]byte)))
+					hash, err := inv.getBlockHashFromElement(v.Estack().Pop())
+					if err != nil {
+						return err
+					}
+					hd, err := inv.getHeader(hash)
+					if err != nil {
+						return err
+					}
 					v.Estack().PushVal(vm.NewInteropItem(hd))
 					return nil
 				},
@@ -159,7 +291,7 @@ func main() {
 			log.Println("[SYSCALL]", "System.Blockchain.GetHeight")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					v.Estack().PushVal(height)
+					v.Estack().PushVal(inv.height)
 					return nil
 				},
 				Price: 1,
@@ -168,7 +300,7 @@ func main() {
 			log.Println("[SYSCALL]", "System.Blockchain.GetTransaction")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					tx, _, err := getTransactionAndHeight(v)
+					tx, _, err := inv.getTransactionAndHeight(v)
 					if err != nil {
 						return err
 					}
@@ -181,10 +313,13 @@ func main() {
 			log.Println("[SYSCALL]", "System.Blockchain.GetTransactionHeight")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					_, h, err := getTransactionAndHeight(v)
+					_, h, err := inv.getTransactionAndHeight(v)
 					if err != nil {
 						return err
 					}
+					if h == chain.UnknownHeight {
+						return errors.New("transaction height is unknown")
+					}
 					v.Estack().PushVal(h)
 					return nil
 				},
@@ -194,8 +329,7 @@ func main() {
 			log.Println("[SYSCALL]", "System.Contract.Destroy")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
-					return nil
+					return inv.destroyContract(getContextScriptHash(v, 0))
 				},
 				Price: 1,
 			}
@@ -241,15 +375,22 @@ func main() {
 			log.Println("[SYSCALL]", "System.ExecutionEngine.GetScriptContainer")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO: IMPL
+					if inv.container == nil {
+						return errors.New("no script container")
+					}
+					v.Estack().PushVal(vm.NewInteropItem(inv.container))
 					return nil
 				},
+				Price: 1,
 			}
 		case vm.InteropNameToID([]byte("System.Header.GetHash")):
 			log.Println("[SYSCALL]", "System.Header.GetHash")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					header := mERR(popHeaderFromVM(v)).(*block.Header)
+					header, err := popHeaderFromVM(v)
+					if err != nil {
+						return err
+					}
 					v.Estack().PushVal(header.Hash().BytesBE())
 					return nil
 				},
@@ -259,7 +400,10 @@ func main() {
 			log.Println("[SYSCALL]", "System.Header.GetIndex")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					header := mERR(popHeaderFromVM(v)).(*block.Header)
+					header, err := popHeaderFromVM(v)
+					if err != nil {
+						return err
+					}
 					v.Estack().PushVal(header.Index)
 					return nil
 				},
@@ -269,7 +413,10 @@ func main() {
 			log.Println("[SYSCALL]", "System.Header.GetPrevHash")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					header := mERR(popHeaderFromVM(v)).(*block.Header)
+					header, err := popHeaderFromVM(v)
+					if err != nil {
+						return err
+					}
 					v.Estack().PushVal(header.PrevHash.BytesBE())
 					return nil
 				},
@@ -279,7 +426,10 @@ func main() {
 			log.Println("[SYSCALL]", "System.Header.GetTimestamp")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					header := mERR(popHeaderFromVM(v)).(*block.Header)
+					header, err := popHeaderFromVM(v)
+					if err != nil {
+						return err
+					}
 					v.Estack().PushVal(header.Timestamp)
 					return nil
 				},
@@ -306,7 +456,7 @@ func main() {
 						}
 						hash = key.GetScriptHash()
 					}
-					if _, ok := witnesses[hash]; ok {
+					if _, ok := inv.witnesses[hash]; ok {
 						v.Estack().PushVal(true)
 					} else {
 						v.Estack().PushVal(false)
@@ -327,20 +477,11 @@ func main() {
 			log.Println("[SYSCALL]", "System.Runtime.GetTime")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					data := map[string]interface{}{
-						"jsonrpc": "2.0",
-						"id":      rand.Uint32(),
-						"method":  "GetHeaderByBlockHeightInHex",
-						"params":  map[string]interface{}{"BlockHeight": height},
+					ts, err := inv.getTime()
+					if err != nil {
+						return err
 					}
-					log.Println("[REQ]", data)
-					resp := mERR(http.Post(rpcaddr, "application/json", bytes.NewReader(mERR(json.Marshal(data)).([]byte)))).(*http.Response)
-					defer resp.Body.Close()
-					mCHK(json.NewDecoder(resp.Body).Decode(&data))
-					log.Println("[RESP]", data)
-					hd := new(block.Header)
-					hd.DecodeBinary(io.NewBinReaderFromBuf(mERR(hex.DecodeString(data["result"].(string))).([]byte)))
-					v.Estack().PushVal(hd.Timestamp)
+					v.Estack().PushVal(ts)
 					return nil
 				},
 				Price: 1,
@@ -349,7 +490,7 @@ func main() {
 			log.Println("[SYSCALL]", "System.Runtime.GetTrigger")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					v.Estack().PushVal(byte(trigger.Application))
+					v.Estack().PushVal(byte(inv.trig))
 					return nil
 				},
 				Price: 1,
@@ -358,7 +499,11 @@ func main() {
 			log.Println("[SYSCALL]", "System.Runtime.Log")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					v.Estack().Pop().Bytes()
+					msg := v.Estack().Pop().Bytes()
+					inv.logs = append(inv.logs, logMessage{
+						ScriptHash: getContextScriptHash(v, 0),
+						Message:    string(msg),
+					})
 					return nil
 				},
 				Price: 1,
@@ -367,7 +512,11 @@ func main() {
 			log.Println("[SYSCALL]", "System.Runtime.Notify")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					v.Estack().Pop()
+					item := v.Estack().Pop().Item()
+					inv.notifications = append(inv.notifications, state.NotificationEvent{
+						ScriptHash: getContextScriptHash(v, 0),
+						Item:       item,
+					})
 					return nil
 				},
 				Price: 1,
@@ -394,13 +543,11 @@ func main() {
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
 					stc := v.Estack().Pop().Value().(*StorageContext)
-					if stc.ReadOnly {
-						return errors.New("StorageContext is read only")
-					}
-					// TODO: CHECK
 					key := v.Estack().Pop().Bytes()
-					sc := hex.EncodeToString(stc.ScriptHash.BytesBE()) + hex.EncodeToString(key)
-					storage[sc] = []byte{}
+					if err := inv.checkStorageWrite(stc, key); err != nil {
+						return err
+					}
+					inv.store.Delete(stc.ScriptHash, key)
 					return nil
 				},
 				Price: 100,
@@ -411,25 +558,15 @@ func main() {
 				Func: func(v *vm.VM) error {
 					stc := v.Estack().Pop().Value().(*StorageContext)
 					key := v.Estack().Pop().Bytes()
-					sc := hex.EncodeToString(stc.ScriptHash.BytesBE()) + hex.EncodeToString(key)
-					if ret, ok := storage[sc]; ok {
-						v.Estack().PushVal(ret)
-					} else {
-						data := map[string]interface{}{
-							"jsonrpc": "2.0",
-							"id":      rand.Uint32(),
-							"method":  "GetStorageByContractHashHexKeyBlockHeightInHex",
-							"params":  map[string]interface{}{"ContractHash": stc.ScriptHash.StringBE(), "HexKey": hex.EncodeToString(key), "BlockHeight": height},
-						}
-						log.Println("[REQ]", data)
-						resp := mERR(http.Post(rpcaddr, "application/json", bytes.NewReader(mERR(json.Marshal(data)).([]byte)))).(*http.Response)
-						defer resp.Body.Close()
-						mCHK(json.NewDecoder(resp.Body).Decode(&data))
-						log.Println("[RESP]", data)
-						val := mERR(hex.DecodeString(data["result"].(string))).([]byte)
-						v.Estack().PushVal(val)
-						storage[sc] = val
+					si, err := inv.store.Get(stc.ScriptHash, key)
+					if errors.Is(err, chain.ErrNotFound) {
+						v.Estack().PushVal([]byte{})
+						return nil
 					}
+					if err != nil {
+						return err
+					}
+					v.Estack().PushVal(si.Value)
 					return nil
 				},
 				Price: 100,
@@ -468,8 +605,10 @@ func main() {
 					stc := v.Estack().Pop().Value().(*StorageContext)
 					key := v.Estack().Pop().Bytes()
 					value := v.Estack().Pop().Bytes()
-					sc := hex.EncodeToString(stc.ScriptHash.BytesBE()) + hex.EncodeToString(key)
-					storage[sc] = value
+					if err := inv.checkStorageWrite(stc, key); err != nil {
+						return err
+					}
+					inv.store.Put(stc.ScriptHash, key, &state.StorageItem{Value: value})
 					return nil
 				},
 				Price: 1000,
@@ -481,10 +620,14 @@ func main() {
 					stc := v.Estack().Pop().Value().(*StorageContext)
 					key := v.Estack().Pop().Bytes()
 					value := v.Estack().Pop().Bytes()
-					sc := hex.EncodeToString(stc.ScriptHash.BytesBE()) + hex.EncodeToString(key)
-					// TODO: IMPL
-					v.Estack().Pop().BigInt().Int64()
-					storage[sc] = value
+					flags := storageFlags(v.Estack().Pop().BigInt().Int64())
+					if err := inv.checkStorageWrite(stc, key); err != nil {
+						return err
+					}
+					inv.store.Put(stc.ScriptHash, key, &state.StorageItem{
+						Value:   value,
+						IsConst: flags&storageConstant != 0,
+					})
 					return nil
 				},
 				Price: 1000,
@@ -520,7 +663,13 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Account.GetBalance")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					acc := v.Estack().Pop().Value().(*state.Account)
+					asset, err := util.Uint256DecodeBytesBE(v.Estack().Pop().Bytes())
+					if err != nil {
+						return err
+					}
+					balance := acc.GetBalanceValues()[asset]
+					v.Estack().PushVal(int64(balance))
 					return nil
 				},
 				Price: 1,
@@ -529,7 +678,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Account.GetScriptHash")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					acc := v.Estack().Pop().Value().(*state.Account)
+					v.Estack().PushVal(acc.ScriptHash.BytesBE())
 					return nil
 				},
 				Price: 1,
@@ -538,7 +688,15 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Account.GetVotes")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					acc := v.Estack().Pop().Value().(*state.Account)
+					if len(acc.Votes) > vm.MaxArraySize {
+						return errors.New("too many votes")
+					}
+					votes := make([]vm.StackItem, 0, len(acc.Votes))
+					for _, key := range acc.Votes {
+						votes = append(votes, vm.NewByteArrayItem(key.Bytes()))
+					}
+					v.Estack().PushVal(votes)
 					return nil
 				},
 				Price: 1,
@@ -547,7 +705,19 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Account.IsStandard")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					hash, err := util.Uint160DecodeBytesBE(v.Estack().Pop().Bytes())
+					if err != nil {
+						return err
+					}
+					cs, err := inv.getContract(hash)
+					if errors.Is(err, chain.ErrNotFound) {
+						v.Estack().PushVal(true)
+						return nil
+					}
+					if err != nil {
+						return err
+					}
+					v.Estack().PushVal(vm.IsStandardContract(cs.Script))
 					return nil
 				},
 				Price: 100,
@@ -556,7 +726,19 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Asset.Create")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					asset, err := inv.popAssetFromVM(v)
+					if err != nil {
+						return err
+					}
+					if existing, err := inv.getAsset(asset.ID); err == nil {
+						asset = existing
+					} else if errors.Is(err, chain.ErrNotFound) {
+						inv.assets[asset.ID] = asset
+						log.Println("[ASSET]", asset.ID.StringLE())
+					} else {
+						return err
+					}
+					v.Estack().PushVal(vm.NewInteropItem(asset))
 					return nil
 				},
 				Price: 0,
@@ -565,7 +747,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Asset.GetAdmin")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					asset := v.Estack().Pop().Value().(*state.Asset)
+					v.Estack().PushVal(asset.Admin.BytesBE())
 					return nil
 				},
 				Price: 1,
@@ -574,7 +757,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Asset.GetAmount")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					asset := v.Estack().Pop().Value().(*state.Asset)
+					v.Estack().PushVal(int64(asset.Amount))
 					return nil
 				},
 				Price: 1,
@@ -583,7 +767,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Asset.GetAssetId")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					asset := v.Estack().Pop().Value().(*state.Asset)
+					v.Estack().PushVal(asset.ID.BytesBE())
 					return nil
 				},
 				Price: 1,
@@ -592,7 +777,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Asset.GetAssetType")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					asset := v.Estack().Pop().Value().(*state.Asset)
+					v.Estack().PushVal(int(asset.AssetType))
 					return nil
 				},
 				Price: 1,
@@ -601,7 +787,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Asset.GetAvailable")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					asset := v.Estack().Pop().Value().(*state.Asset)
+					v.Estack().PushVal(int64(asset.Available))
 					return nil
 				},
 				Price: 1,
@@ -610,7 +797,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Asset.GetIssuer")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					asset := v.Estack().Pop().Value().(*state.Asset)
+					v.Estack().PushVal(asset.Issuer.BytesBE())
 					return nil
 				},
 				Price: 1,
@@ -619,7 +807,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Asset.GetOwner")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					asset := v.Estack().Pop().Value().(*state.Asset)
+					v.Estack().PushVal(asset.Owner.Bytes())
 					return nil
 				},
 				Price: 1,
@@ -628,7 +817,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Asset.GetPrecision")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					asset := v.Estack().Pop().Value().(*state.Asset)
+					v.Estack().PushVal(int(asset.Precision))
 					return nil
 				},
 				Price: 1,
@@ -637,7 +827,23 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Asset.Renew")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					asset := v.Estack().Pop().Value().(*state.Asset)
+					years := uint32(byte(v.Estack().Pop().BigInt().Int64()))
+					asset, err := inv.getAsset(asset.ID)
+					if err != nil {
+						return err
+					}
+					renewed := *asset
+					if renewed.Expiration < inv.height+1 {
+						renewed.Expiration = inv.height + 1
+					}
+					if uint64(renewed.Expiration)+uint64(years)*assetRenewalBlocks > math.MaxUint32 {
+						renewed.Expiration = math.MaxUint32
+					} else {
+						renewed.Expiration += years * assetRenewalBlocks
+					}
+					inv.assets[renewed.ID] = &renewed
+					v.Estack().PushVal(renewed.Expiration)
 					return nil
 				},
 				Price: 0,
@@ -646,7 +852,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Attribute.GetData")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					attr := v.Estack().Pop().Value().(*transaction.Attribute)
+					v.Estack().PushVal(attr.Data)
 					return nil
 				},
 				Price: 1,
@@ -655,7 +862,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Attribute.GetUsage")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					attr := v.Estack().Pop().Value().(*transaction.Attribute)
+					v.Estack().PushVal(int(attr.Usage))
 					return nil
 				},
 				Price: 1,
@@ -703,7 +911,17 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Blockchain.GetAccount")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					hash, err := util.Uint160DecodeBytesBE(v.Estack().Pop().Bytes())
+					if err != nil {
+						return err
+					}
+					acc, err := backend.GetAccount(hash, inv.height)
+					if errors.Is(err, chain.ErrNotFound) {
+						acc = state.NewAccount(hash)
+					} else if err != nil {
+						return err
+					}
+					v.Estack().PushVal(vm.NewInteropItem(acc))
 					return nil
 				},
 				Price: 100,
@@ -712,7 +930,15 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Blockchain.GetAsset")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					id, err := util.Uint256DecodeBytesBE(v.Estack().Pop().Bytes())
+					if err != nil {
+						return err
+					}
+					asset, err := inv.getAsset(id)
+					if err != nil {
+						return err
+					}
+					v.Estack().PushVal(vm.NewInteropItem(asset))
 					return nil
 				},
 				Price: 100,
@@ -721,20 +947,14 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Blockchain.GetBlock")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					hash := mERR(getBlockHashFromElement(v.Estack().Pop())).(util.Uint256)
-					data := map[string]interface{}{
-						"jsonrpc": "2.0",
-						"id":      rand.Uint32(),
-						"method":  "GetBlockByBlockHashInHex",
-						"params":  map[string]interface{}{"BlockHash": hash.StringBE()},
-					}
-					log.Println("[REQ]", data)
-					resp := mERR(http.Post(rpcaddr, "application/json", bytes.NewReader(mERR(json.Marshal(data)).([]byte)))).(*http.Response)
-					defer resp.Body.Close()
-					mCHK(json.NewDecoder(resp.Body).Decode(&data))
-					log.Println("[RESP]", data)
-					blk := new(block.Block)
-					blk.DecodeBinary(io.NewBinReaderFromBuf(mERR(hex.DecodeString(data["result"].(string))).([]byte)))
+					hash, err := inv.getBlockHashFromElement(v.Estack().Pop())
+					if err != nil {
+						return err
+					}
+					blk, err := inv.getBlock(hash)
+					if err != nil {
+						return err
+					}
 					v.Estack().PushVal(vm.NewInteropItem(blk))
 					return nil
 				},
@@ -744,20 +964,14 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Blockchain.GetContract")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					hash := mERR(util.Uint160DecodeBytesBE(v.Estack().Pop().Bytes())).(util.Uint160)
-					data := map[string]interface{}{
-						"jsonrpc": "2.0",
-						"id":      rand.Uint32(),
-						"method":  "GetContractByContractHashBlockHeightInHex",
-						"params":  map[string]interface{}{"ContractHash": hash.StringBE(), "BlockHeight": height},
-					}
-					log.Println("[REQ]", data)
-					resp := mERR(http.Post(rpcaddr, "application/json", bytes.NewReader(mERR(json.Marshal(data)).([]byte)))).(*http.Response)
-					defer resp.Body.Close()
-					mCHK(json.NewDecoder(resp.Body).Decode(&data))
-					log.Println("[RESP]", data)
-					cs := new(state.Contract)
-					cs.DecodeBinary(io.NewBinReaderFromBuf(mERR(hex.DecodeString(data["result"].(string))).([]byte)[1:]))
+					hash, err := util.Uint160DecodeBytesBE(v.Estack().Pop().Bytes())
+					if err != nil {
+						return err
+					}
+					cs, err := inv.getContract(hash)
+					if err != nil {
+						return err
+					}
 					v.Estack().PushVal(vm.NewInteropItem(cs))
 					return nil
 				},
@@ -767,20 +981,14 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Blockchain.GetHeader")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					hash := mERR(getBlockHashFromElement(v.Estack().Pop())).(util.Uint256)
-					data := map[string]interface{}{
-						"jsonrpc": "2.0",
-						"id":      rand.Uint32(),
-						"method":  "GetHeaderByBlockHashInHex",
-						"params":  map[string]interface{}{"BlockHash": hash.StringBE()},
-					}
-					log.Println("[REQ]", data)
-					resp := mERR(http.Post(rpcaddr, "application/json", bytes.NewReader(mERR(json.Marshal(data)).([]byte)))).(*http.Response)
-					defer resp.Body.Close()
-					mCHK(json.NewDecoder(resp.Body).Decode(&data))
-					log.Println("[RESP]", data)
-					hd := new(block.Header)
-					hd.DecodeBinary(io.NewBinReaderFromBuf(mERR(hex.DecodeString(data["result"].(string))).([]byte)))
+					hash, err := inv.getBlockHashFromElement(v.Estack().Pop())
+					if err != nil {
+						return err
+					}
+					hd, err := inv.getHeader(hash)
+					if err != nil {
+						return err
+					}
 					v.Estack().PushVal(vm.NewInteropItem(hd))
 					return nil
 				},
@@ -790,7 +998,7 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Blockchain.GetHeight")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					v.Estack().PushVal(height)
+					v.Estack().PushVal(inv.height)
 					return nil
 				},
 				Price: 1,
@@ -799,7 +1007,7 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Blockchain.GetTransaction")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					tx, _, err := getTransactionAndHeight(v)
+					tx, _, err := inv.getTransactionAndHeight(v)
 					if err != nil {
 						return err
 					}
@@ -812,10 +1020,13 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Blockchain.GetTransactionHeight")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					_, h, err := getTransactionAndHeight(v)
+					_, h, err := inv.getTransactionAndHeight(v)
 					if err != nil {
 						return err
 					}
+					if h == chain.UnknownHeight {
+						return errors.New("transaction height is unknown")
+					}
 					v.Estack().PushVal(h)
 					return nil
 				},
@@ -825,7 +1036,15 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Blockchain.GetValidators")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					pubs, err := backend.GetValidators(inv.height)
+					if err != nil {
+						return err
+					}
+					validators := make([]vm.StackItem, 0, len(pubs))
+					for _, pub := range pubs {
+						validators = append(validators, vm.NewByteArrayItem(pub.Bytes()))
+					}
+					v.Estack().PushVal(validators)
 					return nil
 				},
 				Price: 200,
@@ -834,17 +1053,24 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Contract.Create")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					cs, err := popContractFromVM(v)
+					if err != nil {
+						return err
+					}
+					cs, _, err = inv.deployContract(cs)
+					if err != nil {
+						return err
+					}
+					v.Estack().PushVal(vm.NewInteropItem(cs))
 					return nil
 				},
-				Price: 1,
+				Price: 0,
 			}
 		case vm.InteropNameToID([]byte("Neo.Contract.Destroy")):
 			log.Println("[SYSCALL]", "Neo.Contract.Destroy")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
-					return nil
+					return inv.destroyContract(getContextScriptHash(v, 0))
 				},
 				Price: 1,
 			}
@@ -852,7 +1078,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Contract.GetScript")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					cs := v.Estack().Pop().Value().(*state.Contract)
+					v.Estack().PushVal(cs.Script)
 					return nil
 				},
 				Price: 1,
@@ -875,7 +1102,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Contract.IsPayable")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					cs := v.Estack().Pop().Value().(*state.Contract)
+					v.Estack().PushVal(cs.IsPayable())
 					return nil
 				},
 				Price: 1,
@@ -884,52 +1112,62 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Contract.Migrate")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
-					return nil
+					cs, err := popContractFromVM(v)
+					if err != nil {
+						return err
+					}
+					cs, created, err := inv.deployContract(cs)
+					if err != nil {
+						return err
+					}
+					current := getContextScriptHash(v, 0)
+					if created && cs.HasStorage() {
+						kvs, err := inv.store.Find(current, nil)
+						if err != nil {
+							return err
+						}
+						for _, kv := range kvs {
+							inv.store.Put(cs.ScriptHash(), kv.Key, &state.StorageItem{Value: kv.Value})
+						}
+					}
+					v.Estack().PushVal(vm.NewInteropItem(cs))
+					return inv.destroyContract(current)
 				},
-				Price: 1,
+				Price: 0,
 			}
 		case vm.InteropNameToID([]byte("Neo.Enumerator.Concat")):
 			log.Println("[SYSCALL]", "Neo.Enumerator.Concat")
 			return &vm.InteropFuncPrice{
-				Func: func(v *vm.VM) error {
-					// TODO : IMPL
-					return nil
-				},
+				Func:  vm.EnumeratorConcat,
 				Price: 1,
 			}
 		case vm.InteropNameToID([]byte("Neo.Enumerator.Create")):
 			log.Println("[SYSCALL]", "Neo.Enumerator.Create")
 			return &vm.InteropFuncPrice{
-				Func: func(v *vm.VM) error {
-					// TODO : IMPL
-					return nil
-				},
+				Func:  vm.EnumeratorCreate,
 				Price: 1,
 			}
 		case vm.InteropNameToID([]byte("Neo.Enumerator.Next")):
 			log.Println("[SYSCALL]", "Neo.Enumerator.Next")
 			return &vm.InteropFuncPrice{
-				Func: func(v *vm.VM) error {
-					// TODO : IMPL
-					return nil
-				},
+				Func:  vm.EnumeratorNext,
 				Price: 1,
 			}
 		case vm.InteropNameToID([]byte("Neo.Enumerator.Value")):
 			log.Println("[SYSCALL]", "Neo.Enumerator.Value")
 			return &vm.InteropFuncPrice{
-				Func: func(v *vm.VM) error {
-					// TODO : IMPL
-					return nil
-				},
+				Func:  vm.EnumeratorValue,
 				Price: 1,
 			}
 		case vm.InteropNameToID([]byte("Neo.Header.GetConsensusData")):
 			log.Println("[SYSCALL]", "Neo.Header.GetConsensusData")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					header, err := popHeaderFromVM(v)
+					if err != nil {
+						return err
+					}
+					v.Estack().PushVal(header.ConsensusData)
 					return nil
 				},
 				Price: 1,
@@ -938,7 +1176,10 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Header.GetHash")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					header := mERR(popHeaderFromVM(v)).(*block.Header)
+					header, err := popHeaderFromVM(v)
+					if err != nil {
+						return err
+					}
 					v.Estack().PushVal(header.Hash().BytesBE())
 					return nil
 				},
@@ -948,7 +1189,10 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Header.GetIndex")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					header := mERR(popHeaderFromVM(v)).(*block.Header)
+					header, err := popHeaderFromVM(v)
+					if err != nil {
+						return err
+					}
 					v.Estack().PushVal(header.Index)
 					return nil
 				},
@@ -958,7 +1202,11 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Header.GetMerkleRoot")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					header, err := popHeaderFromVM(v)
+					if err != nil {
+						return err
+					}
+					v.Estack().PushVal(header.MerkleRoot.BytesBE())
 					return nil
 				},
 				Price: 1,
@@ -967,7 +1215,11 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Header.GetNextConsensus")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					header, err := popHeaderFromVM(v)
+					if err != nil {
+						return err
+					}
+					v.Estack().PushVal(header.NextConsensus.BytesBE())
 					return nil
 				},
 				Price: 1,
@@ -976,7 +1228,10 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Header.GetPrevHash")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					header := mERR(popHeaderFromVM(v)).(*block.Header)
+					header, err := popHeaderFromVM(v)
+					if err != nil {
+						return err
+					}
 					v.Estack().PushVal(header.PrevHash.BytesBE())
 					return nil
 				},
@@ -986,7 +1241,10 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Header.GetTimestamp")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					header := mERR(popHeaderFromVM(v)).(*block.Header)
+					header, err := popHeaderFromVM(v)
+					if err != nil {
+						return err
+					}
 					v.Estack().PushVal(header.Timestamp)
 					return nil
 				},
@@ -996,7 +1254,11 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Header.GetVersion")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					header, err := popHeaderFromVM(v)
+					if err != nil {
+						return err
+					}
+					v.Estack().PushVal(header.Version)
 					return nil
 				},
 				Price: 1,
@@ -1005,7 +1267,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Input.GetHash")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					input := v.Estack().Pop().Value().(*transaction.Input)
+					v.Estack().PushVal(input.PrevHash.BytesBE())
 					return nil
 				},
 				Price: 1,
@@ -1014,7 +1277,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Input.GetIndex")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					input := v.Estack().Pop().Value().(*transaction.Input)
+					v.Estack().PushVal(input.PrevIndex)
 					return nil
 				},
 				Price: 1,
@@ -1023,7 +1287,14 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.InvocationTransaction.GetScript")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					tx := v.Estack().Pop().Value().(*transaction.Transaction)
+					itx, ok := tx.Data.(*transaction.InvocationTX)
+					if !ok {
+						return errors.New("value is not an invocation transaction")
+					}
+					script := make([]byte, len(itx.Script))
+					copy(script, itx.Script)
+					v.Estack().PushVal(script)
 					return nil
 				},
 				Price: 1,
@@ -1031,53 +1302,51 @@ func main() {
 		case vm.InteropNameToID([]byte("Neo.Iterator.Concat")):
 			log.Println("[SYSCALL]", "Neo.Iterator.Concat")
 			return &vm.InteropFuncPrice{
-				Func: func(v *vm.VM) error {
-					// TODO : IMPL
-					return nil
-				},
+				Func:  vm.IteratorConcat,
 				Price: 1,
 			}
 		case vm.InteropNameToID([]byte("Neo.Iterator.Create")):
 			log.Println("[SYSCALL]", "Neo.Iterator.Create")
 			return &vm.InteropFuncPrice{
-				Func: func(v *vm.VM) error {
-					// TODO : IMPL
-					return nil
-				},
+				Func:  vm.IteratorCreate,
 				Price: 1,
 			}
 		case vm.InteropNameToID([]byte("Neo.Iterator.Key")):
 			log.Println("[SYSCALL]", "Neo.Iterator.Key")
 			return &vm.InteropFuncPrice{
-				Func: func(v *vm.VM) error {
-					// TODO : IMPL
-					return nil
-				},
+				Func:  vm.IteratorKey,
 				Price: 1,
 			}
 		case vm.InteropNameToID([]byte("Neo.Iterator.Keys")):
 			log.Println("[SYSCALL]", "Neo.Iterator.Keys")
 			return &vm.InteropFuncPrice{
-				Func: func(v *vm.VM) error {
-					// TODO : IMPL
-					return nil
-				},
+				Func:  vm.IteratorKeys,
+				Price: 1,
+			}
+		case vm.InteropNameToID([]byte("Neo.Iterator.Next")):
+			log.Println("[SYSCALL]", "Neo.Iterator.Next")
+			return &vm.InteropFuncPrice{
+				Func:  vm.EnumeratorNext,
+				Price: 1,
+			}
+		case vm.InteropNameToID([]byte("Neo.Iterator.Value")):
+			log.Println("[SYSCALL]", "Neo.Iterator.Value")
+			return &vm.InteropFuncPrice{
+				Func:  vm.EnumeratorValue,
 				Price: 1,
 			}
 		case vm.InteropNameToID([]byte("Neo.Iterator.Values")):
 			log.Println("[SYSCALL]", "Neo.Iterator.Values")
 			return &vm.InteropFuncPrice{
-				Func: func(v *vm.VM) error {
-					// TODO : IMPL
-					return nil
-				},
+				Func:  vm.IteratorValues,
 				Price: 1,
 			}
 		case vm.InteropNameToID([]byte("Neo.Output.GetAssetId")):
 			log.Println("[SYSCALL]", "Neo.Output.GetAssetId")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					output := v.Estack().Pop().Value().(*transaction.Output)
+					v.Estack().PushVal(output.AssetID.BytesBE())
 					return nil
 				},
 				Price: 1,
@@ -1086,7 +1355,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Output.GetScriptHash")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					output := v.Estack().Pop().Value().(*transaction.Output)
+					v.Estack().PushVal(output.ScriptHash.BytesBE())
 					return nil
 				},
 				Price: 1,
@@ -1095,7 +1365,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Output.GetValue")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					output := v.Estack().Pop().Value().(*transaction.Output)
+					v.Estack().PushVal(int64(output.Amount))
 					return nil
 				},
 				Price: 1,
@@ -1121,7 +1392,7 @@ func main() {
 						}
 						hash = key.GetScriptHash()
 					}
-					if _, ok := witnesses[hash]; ok {
+					if _, ok := inv.witnesses[hash]; ok {
 						v.Estack().PushVal(true)
 					} else {
 						v.Estack().PushVal(false)
@@ -1142,20 +1413,11 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Runtime.GetTime")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					data := map[string]interface{}{
-						"jsonrpc": "2.0",
-						"id":      rand.Uint32(),
-						"method":  "GetHeaderByBlockHeightInHex",
-						"params":  map[string]interface{}{"BlockHeight": height},
+					ts, err := inv.getTime()
+					if err != nil {
+						return err
 					}
-					log.Println("[REQ]", data)
-					resp := mERR(http.Post(rpcaddr, "application/json", bytes.NewReader(mERR(json.Marshal(data)).([]byte)))).(*http.Response)
-					defer resp.Body.Close()
-					mCHK(json.NewDecoder(resp.Body).Decode(&data))
-					log.Println("[RESP]", data)
-					hd := new(block.Header)
-					hd.DecodeBinary(io.NewBinReaderFromBuf(mERR(hex.DecodeString(data["result"].(string))).([]byte)))
-					v.Estack().PushVal(hd.Timestamp)
+					v.Estack().PushVal(ts)
 					return nil
 				},
 				Price: 1,
@@ -1164,7 +1426,7 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Runtime.GetTrigger")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					v.Estack().PushVal(byte(trigger.Application))
+					v.Estack().PushVal(byte(inv.trig))
 					return nil
 				},
 				Price: 1,
@@ -1173,7 +1435,11 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Runtime.Log")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					v.Estack().Pop().Bytes()
+					msg := v.Estack().Pop().Bytes()
+					inv.logs = append(inv.logs, logMessage{
+						ScriptHash: getContextScriptHash(v, 0),
+						Message:    string(msg),
+					})
 					return nil
 				},
 				Price: 1,
@@ -1182,7 +1448,11 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Runtime.Notify")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					v.Estack().Pop()
+					item := v.Estack().Pop().Item()
+					inv.notifications = append(inv.notifications, state.NotificationEvent{
+						ScriptHash: getContextScriptHash(v, 0),
+						Item:       item,
+					})
 					return nil
 				},
 				Price: 1,
@@ -1200,13 +1470,11 @@ func main() {
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
 					stc := v.Estack().Pop().Value().(*StorageContext)
-					if stc.ReadOnly {
-						return errors.New("StorageContext is read only")
-					}
-					// TODO: CHECK
 					key := v.Estack().Pop().Bytes()
-					sc := hex.EncodeToString(stc.ScriptHash.BytesBE()) + hex.EncodeToString(key)
-					storage[sc] = []byte{}
+					if err := inv.checkStorageWrite(stc, key); err != nil {
+						return err
+					}
+					inv.store.Delete(stc.ScriptHash, key)
 					return nil
 				},
 				Price: 100,
@@ -1215,8 +1483,18 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Storage.Find")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
-					return nil
+					stc := v.Estack().Pop().Value().(*StorageContext)
+					prefix := v.Estack().Pop().Bytes()
+					kvs, err := inv.store.Find(stc.ScriptHash, prefix)
+					if err != nil {
+						return err
+					}
+					m := vm.NewMapItem()
+					for _, kv := range kvs {
+						m.Add(vm.NewByteArrayItem(kv.Key), vm.NewByteArrayItem(kv.Value))
+					}
+					v.Estack().PushVal(vm.NewMapIterator(m))
+					return nil
 				},
 				Price: 1,
 			}
@@ -1226,25 +1504,15 @@ func main() {
 				Func: func(v *vm.VM) error {
 					stc := v.Estack().Pop().Value().(*StorageContext)
 					key := v.Estack().Pop().Bytes()
-					sc := hex.EncodeToString(stc.ScriptHash.BytesBE()) + hex.EncodeToString(key)
-					if ret, ok := storage[sc]; ok {
-						v.Estack().PushVal(ret)
-					} else {
-						data := map[string]interface{}{
-							"jsonrpc": "2.0",
-							"id":      rand.Uint32(),
-							"method":  "GetStorageByContractHashHexKeyBlockHeightInHex",
-							"params":  map[string]interface{}{"ContractHash": stc.ScriptHash.StringBE(), "HexKey": hex.EncodeToString(key), "BlockHeight": height},
-						}
-						log.Println("[REQ]", data)
-						resp := mERR(http.Post(rpcaddr, "application/json", bytes.NewReader(mERR(json.Marshal(data)).([]byte)))).(*http.Response)
-						defer resp.Body.Close()
-						mCHK(json.NewDecoder(resp.Body).Decode(&data))
-						log.Println("[RESP]", data)
-						val := mERR(hex.DecodeString(data["result"].(string))).([]byte)
-						v.Estack().PushVal(val)
-						storage[sc] = val
+					si, err := inv.store.Get(stc.ScriptHash, key)
+					if errors.Is(err, chain.ErrNotFound) {
+						v.Estack().PushVal([]byte{})
+						return nil
+					}
+					if err != nil {
+						return err
 					}
+					v.Estack().PushVal(si.Value)
 					return nil
 				},
 				Price: 100,
@@ -1282,8 +1550,10 @@ func main() {
 					stc := v.Estack().Pop().Value().(*StorageContext)
 					key := v.Estack().Pop().Bytes()
 					value := v.Estack().Pop().Bytes()
-					sc := hex.EncodeToString(stc.ScriptHash.BytesBE()) + hex.EncodeToString(key)
-					storage[sc] = value
+					if err := inv.checkStorageWrite(stc, key); err != nil {
+						return err
+					}
+					inv.store.Put(stc.ScriptHash, key, &state.StorageItem{Value: value})
 					return nil
 				},
 				Price: 1000,
@@ -1309,7 +1579,15 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Transaction.GetAttributes")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					tx := v.Estack().Pop().Value().(*transaction.Transaction)
+					if len(tx.Attributes) > vm.MaxArraySize {
+						return errors.New("too many attributes")
+					}
+					attrs := make([]vm.StackItem, 0, len(tx.Attributes))
+					for i := range tx.Attributes {
+						attrs = append(attrs, vm.NewInteropItem(&tx.Attributes[i]))
+					}
+					v.Estack().PushVal(attrs)
 					return nil
 				},
 				Price: 1,
@@ -1328,7 +1606,15 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Transaction.GetInputs")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					tx := v.Estack().Pop().Value().(*transaction.Transaction)
+					if len(tx.Inputs) > vm.MaxArraySize {
+						return errors.New("too many inputs")
+					}
+					inputs := make([]vm.StackItem, 0, len(tx.Inputs))
+					for i := range tx.Inputs {
+						inputs = append(inputs, vm.NewInteropItem(&tx.Inputs[i]))
+					}
+					v.Estack().PushVal(inputs)
 					return nil
 				},
 				Price: 1,
@@ -1337,7 +1623,15 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Transaction.GetOutputs")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					tx := v.Estack().Pop().Value().(*transaction.Transaction)
+					if len(tx.Outputs) > vm.MaxArraySize {
+						return errors.New("too many outputs")
+					}
+					outputs := make([]vm.StackItem, 0, len(tx.Outputs))
+					for i := range tx.Outputs {
+						outputs = append(outputs, vm.NewInteropItem(&tx.Outputs[i]))
+					}
+					v.Estack().PushVal(outputs)
 					return nil
 				},
 				Price: 1,
@@ -1346,7 +1640,22 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Transaction.GetReferences")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					tx := v.Estack().Pop().Value().(*transaction.Transaction)
+					if len(tx.Inputs) > vm.MaxArraySize {
+						return errors.New("too many inputs")
+					}
+					refs := make([]vm.StackItem, 0, len(tx.Inputs))
+					for _, input := range tx.Inputs {
+						prev, _, err := inv.getTransaction(input.PrevHash)
+						if err != nil {
+							return err
+						}
+						if int(input.PrevIndex) >= len(prev.Outputs) {
+							return errors.New("bad input reference")
+						}
+						refs = append(refs, vm.NewInteropItem(&prev.Outputs[input.PrevIndex]))
+					}
+					v.Estack().PushVal(refs)
 					return nil
 				},
 				Price: 200,
@@ -1355,7 +1664,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Transaction.GetType")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					tx := v.Estack().Pop().Value().(*transaction.Transaction)
+					v.Estack().PushVal(int(tx.Type))
 					return nil
 				},
 				Price: 1,
@@ -1364,8 +1674,8 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Transaction.GetUnspentCoins")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
-					return nil
+					// None of the backends provides spent state of outputs.
+					return errors.New("unspent coins are not available")
 				},
 				Price: 200,
 			}
@@ -1373,7 +1683,15 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Transaction.GetWitnesses")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					tx := v.Estack().Pop().Value().(*transaction.Transaction)
+					if len(tx.Scripts) > vm.MaxArraySize {
+						return errors.New("too many witnesses")
+					}
+					scripts := make([]vm.StackItem, 0, len(tx.Scripts))
+					for i := range tx.Scripts {
+						scripts = append(scripts, vm.NewInteropItem(&tx.Scripts[i]))
+					}
+					v.Estack().PushVal(scripts)
 					return nil
 				},
 				Price: 200,
@@ -1382,7 +1700,10 @@ func main() {
 			log.Println("[SYSCALL]", "Neo.Witness.GetVerificationScript")
 			return &vm.InteropFuncPrice{
 				Func: func(v *vm.VM) error {
-					// TODO : IMPL
+					witness := v.Estack().Pop().Value().(*transaction.Witness)
+					script := make([]byte, len(witness.VerificationScript))
+					copy(script, witness.VerificationScript)
+					v.Estack().PushVal(script)
 					return nil
 				},
 				Price: 100,
@@ -1390,111 +1711,1072 @@ func main() {
 		}
 		return nil
 	})
-	nvm.SetGasLimit(util.Fixed8(gaslimit))
-	nvm.LoadScript(script)
-	err := nvm.Run()
+	nvm.RegisterInteropGetter(func(id uint32) *vm.InteropFuncPrice {
+		if !isVerification(inv.trig) {
+			return nil
+		}
+		if _, ok := stateChangingSyscalls[id]; !ok {
+			return nil
+		}
+		return &vm.InteropFuncPrice{
+			Func: func(v *vm.VM) error {
+				return fmt.Errorf("state-changing syscall with %s trigger", inv.trig)
+			},
+		}
+	})
+	if inv.container != nil {
+		// CHECKSIG and CHECKMULTISIG verify signatures of the container's
+		// signed part.
+		nvm.SetCheckedHash(inv.container.VerificationHash().BytesBE())
+	}
+	return nvm
+}
+
+// verifyWitnesses runs every witness of the script container under the
+// Verification trigger the same way C# node does it and returns the result
+// for each of them.
+func (inv *invocation) verifyWitnesses() (map[string]interface{}, error) {
+	if inv.container == nil {
+		return nil, errors.New("no transaction to verify")
+	}
+	inv.trig = trigger.Verification
+	hashes, err := inv.getScriptHashesForVerifying(inv.container)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
 	}
-	result := map[string]interface{}{
-		"script":       hex.EncodeToString(script),
-		"state":        nvm.State(),
-		"gas_consumed": nvm.GasConsumed(),
-		"stack":        nvm.Estack().ToContractParameters(),
+	verified := len(hashes) == len(inv.container.Scripts)
+	results := make([]witnessResult, 0, len(hashes))
+	root := inv.store
+	for i, hash := range hashes {
+		res := witnessResult{ScriptHash: hash}
+		switch {
+		case i >= len(inv.container.Scripts):
+			res.Error = "no witness"
+		case len(inv.container.Scripts[i].VerificationScript) != 0 && !inv.container.Scripts[i].ScriptHash().Equals(hash):
+			res.Error = "witness script hash mismatch"
+		default:
+			witness := inv.container.Scripts[i]
+			verification := witness.VerificationScript
+			if len(verification) == 0 {
+				// Contract account, its verification script is deployed.
+				buf := io.NewBufBinWriter()
+				emit.AppCall(buf.BinWriter, hash, false)
+				verification = buf.Bytes()
+			}
+			// Each witness is verified against the same state.
+			inv.store = root.Fork()
+			nvm := inv.newVM()
+			nvm.SetGasLimit(verificationGasLimit)
+			nvm.LoadScript(verification)
+			nvm.LoadScript(witness.InvocationScript)
+			if err := nvm.Run(); err != nil {
+				res.Error = err.Error()
+			}
+			inv.store.Discard()
+			inv.store = root
+			res.State = nvm.State()
+			res.GasConsumed = nvm.GasConsumed()
+			res.Verified = isVerified(nvm)
+		}
+		log.Println("[WITNESS]", hash.StringBE(), res.Verified)
+		verified = verified && res.Verified
+		results = append(results, res)
+	}
+	return map[string]interface{}{
+		"txid":      inv.container.Hash(),
+		"verified":  verified,
+		"witnesses": results,
+	}, nil
+}
+
+// getScriptHashesForVerifying returns sorted script hashes the transaction
+// must be witnessed by: owners of the outputs referenced by its inputs,
+// recipients of assets with DutyFlag and hashes from its Script attributes.
+func (inv *invocation) getScriptHashesForVerifying(tx *transaction.Transaction) ([]util.Uint160, error) {
+	seen := make(map[util.Uint160]struct{})
+	for _, input := range tx.Inputs {
+		prev, _, err := inv.getTransaction(input.PrevHash)
+		if err != nil {
+			return nil, err
+		}
+		if int(input.PrevIndex) >= len(prev.Outputs) {
+			return nil, errors.New("bad input reference")
+		}
+		seen[prev.Outputs[input.PrevIndex].ScriptHash] = struct{}{}
+	}
+	for id, outputs := range tx.GroupOutputByAssetID() {
+		asset, err := inv.getAsset(id)
+		if err != nil {
+			return nil, err
+		}
+		if asset.AssetType&transaction.DutyFlag == 0 {
+			continue
+		}
+		for _, output := range outputs {
+			seen[output.ScriptHash] = struct{}{}
+		}
+	}
+	for _, attr := range tx.Attributes {
+		if attr.Usage != transaction.Script {
+			continue
+		}
+		hash, err := util.Uint160DecodeBytesBE(attr.Data)
+		if err != nil {
+			return nil, err
+		}
+		seen[hash] = struct{}{}
+	}
+	hashes := make([]util.Uint160, 0, len(seen))
+	for hash := range seen {
+		hashes = append(hashes, hash)
+	}
+	// C# node compares hashes as numbers, that is starting from the last byte.
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i].Reverse().Less(hashes[j].Reverse())
+	})
+	return hashes, nil
+}
+
+// saveRecording saves backend traffic to the cassette if recording.
+func saveRecording() {
+	if recorder != nil {
+		if err := recorder.Save(record); err != nil {
+			log.Println("[RECORD]", err)
+		}
+	}
+}
+
+// serveRPC serves invokescript and invokefunction JSON-RPC requests on the
+// given address. The recording is saved periodically and on shutdown.
+func serveRPC(addr string) error {
+	// Blocks and contracts are immutable, so they're shared by all requests.
+	backend = chain.NewCache(backend, serveCacheSize)
+	if recorder != nil {
+		go saveRecordingPeriodically()
+	}
+	http.HandleFunc("/", handleRPC)
+	log.Println("[SERVE]", addr)
+	err := http.ListenAndServe(addr, nil)
+	saveRecording()
+	return err
+}
+
+// saveRecordingPeriodically saves the recording every recordInterval until
+// SIGINT or SIGTERM is received, it's saved and the process exits then.
+func saveRecordingPeriodically() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(recordInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			saveRecording()
+		case sig := <-sigs:
+			log.Println("[SERVE]", sig)
+			saveRecording()
+			os.Exit(0)
+		}
+	}
+}
+
+// handleRPC handles a single JSON-RPC request.
+func handleRPC(w http.ResponseWriter, r *http.Request) {
+	var req rpcRequest
+	resp := rpcResponse{JSONRPC: "2.0"}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.Error = &rpcError{Code: -32700, Message: err.Error()}
+	} else {
+		resp.ID = req.ID
+		resp.Result, resp.Error = invokeRPC(req.Method, req.Params)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Println("[SERVE]", err)
+	}
+}
+
+// invokeRPC runs the invocation requested with the given JSON-RPC method.
+func invokeRPC(method string, params json.RawMessage) (map[string]interface{}, *rpcError) {
+	var req invokeRequest
+	switch method {
+	case "invokescript":
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, &rpcError{Code: -32602, Message: err.Error()}
+		}
+	case "invokefunction":
+		var fn invokeFunctionRequest
+		if err := json.Unmarshal(params, &fn); err != nil {
+			return nil, &rpcError{Code: -32602, Message: err.Error()}
+		}
+		script, err := fn.buildScript()
+		if err != nil {
+			return nil, &rpcError{Code: -32602, Message: err.Error()}
+		}
+		req = fn.invokeRequest
+		req.Script = hex.EncodeToString(script)
+	default:
+		return nil, &rpcError{Code: -32601, Message: "method not found: " + method}
+	}
+	inv, err := newInvocation(&req)
+	if err != nil {
+		e := newInvocationError(err, errorKindRequest)
+		return nil, &rpcError{Code: -32602, Message: err.Error(), Data: e}
+	}
+	res, err := inv.run()
+	if err != nil {
+		e := newInvocationError(err, errorKindFault)
+		return nil, &rpcError{Code: -32000, Message: err.Error(), Data: e}
+	}
+	return res, nil
+}
+
+// buildScript returns a script calling the requested contract method.
+func (r *invokeFunctionRequest) buildScript() ([]byte, error) {
+	hash, err := decodeScriptHash(r.Contract)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, 0, len(r.Params))
+	for _, p := range r.Params {
+		arg, err := parameterToArg(p)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	buf := io.NewBufBinWriter()
+	emit.AppCallWithOperationAndArgs(buf.BinWriter, hash, r.Operation, args...)
+	if buf.Err != nil {
+		return nil, buf.Err
+	}
+	return buf.Bytes(), nil
+}
+
+// parameterToArg converts the parameter to a value emit.Array understands.
+func parameterToArg(p smartcontract.Parameter) (interface{}, error) {
+	switch p.Type {
+	case smartcontract.BoolType, smartcontract.IntegerType, smartcontract.StringType,
+		smartcontract.ByteArrayType, smartcontract.SignatureType, smartcontract.PublicKeyType,
+		smartcontract.Hash160Type, smartcontract.Hash256Type:
+		return p.Value, nil
+	case smartcontract.ArrayType:
+		ps := p.Value.([]smartcontract.Parameter)
+		arr := make([]interface{}, 0, len(ps))
+		for _, p := range ps {
+			arg, err := parameterToArg(p)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, arg)
+		}
+		return arr, nil
+	case smartcontract.MapType:
+		pairs := p.Value.([]smartcontract.ParameterPair)
+		m := make([]emit.MapEntry, 0, len(pairs))
+		for _, pair := range pairs {
+			k, err := parameterToArg(pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			v, err := parameterToArg(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, emit.MapEntry{Key: k, Value: v})
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unsupported parameter type %s", p.Type)
+	}
+}
+
+// parseParameters parses invokefunction parameters given either as a JSON
+// array of smartcontract.Parameter or as separate arguments in
+// smartcontract.NewParameterFromString syntax.
+func parseParameters(js string, args []string) ([]smartcontract.Parameter, error) {
+	var params []smartcontract.Parameter
+	if len(js) > 0 {
+		if err := json.Unmarshal([]byte(js), &params); err != nil {
+			return nil, err
+		}
+	}
+	for _, arg := range args {
+		p, err := smartcontract.NewParameterFromString(arg)
+		if err != nil {
+			return nil, fmt.Errorf("bad parameter %q: %w", arg, err)
+		}
+		params = append(params, *p)
+	}
+	return params, nil
+}
+
+// decodeScriptHash decodes a script hash given either in the 0x-prefixed
+// form used by RPC or as BE hex.
+func decodeScriptHash(s string) (util.Uint160, error) {
+	if strings.HasPrefix(s, "0x") {
+		return util.Uint160DecodeStringLE(s[2:])
+	}
+	return util.Uint160DecodeStringBE(s)
+}
+
+// runBatch runs invocations read line by line from the given file (stdin for
+// "-") on the given number of workers. Results are printed one per line in
+// the order of requests.
+func runBatch(name string, workers int) error {
+	in := os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	if workers < 1 {
+		return errors.New("at least one worker is required")
+	}
+	backend = chain.NewCache(backend, serveCacheSize)
+
+	type job struct {
+		line []byte
+		res  chan []byte
+	}
+	jobs := make(chan job, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := range jobs {
+				j.res <- runBatchLine(j.line)
+			}
+		}()
+	}
+	// Results are queued in the order of requests, each one is waited for
+	// before the next one is printed.
+	results := make(chan chan []byte, workers)
+	var scanErr error
+	go func() {
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(nil, maxBatchLineSize)
+		for scanner.Scan() {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			j := job{
+				line: append([]byte(nil), scanner.Bytes()...),
+				res:  make(chan []byte, 1),
+			}
+			results <- j.res
+			jobs <- j
+		}
+		scanErr = scanner.Err()
+		close(jobs)
+		close(results)
+	}()
+	for res := range results {
+		fmt.Println(string(<-res))
+	}
+	return scanErr
+}
+
+// runBatchLine runs the invocation described by a single batch line and
+// returns its result JSON. Failures are reported as an object with the error
+// message.
+func runBatchLine(line []byte) []byte {
+	var result map[string]interface{}
+	var req invokeRequest
+	err := json.Unmarshal(line, &req)
+	if err == nil {
+		var inv *invocation
+		inv, err = newInvocation(&req)
+		if err == nil {
+			result, err = inv.run()
+		}
+	}
+	if err != nil {
+		result = map[string]interface{}{"error": newInvocationError(err, errorKindRequest)}
 	}
 	res, err := json.Marshal(result)
 	if err != nil {
-		log.Fatalln(err)
+		res, _ = json.Marshal(map[string]interface{}{"error": newInvocationError(err, errorKindRequest)})
 	}
-	fmt.Println(string(res))
+	return res
 }
 
 func init() {
-	var hexscript string
-	var wits string
 	flag.StringVar(&hexscript, "script", "", "scriptHexFormat")
-	flag.Int64Var(&gaslimit, "gaslimit", 50000000000, "gaslimit")
-	flag.StringVar(&rpcaddr, "rpc", "", "rpcaddr")
+	flag.Int64Var(&gaslimit, "gaslimit", defaultGasLimit, "gaslimit")
+	flag.StringVar(&rpcaddr, "rpc", "", "comma-separated rpc addresses, the next one is used when the current one fails")
+	flag.DurationVar(&rpcopts.Timeout, "rpctimeout", rpc.DefaultTimeout, "rpc request timeout")
+	flag.IntVar(&rpcopts.Retries, "rpcretries", rpc.DefaultRetries, "number of times a failed rpc request is retried (0 disables retries)")
+	flag.BoolVar(&neocli, "neocli", false, "use standard neo-cli rpc methods (current state only, no storage search)")
+	flag.StringVar(&cachedir, "cache", "", "directory to cache blocks, headers and contracts fetched via rpc in")
+	flag.Int64Var(&cachesize, "cachesize", defaultCacheSize, "cache directory size limit in bytes")
 	flag.StringVar(&wits, "wits", "", "witnesses")
+	flag.StringVar(&snapshot, "snapshot", "", "snapshot file to serve chain state from instead of rpc")
+	flag.StringVar(&record, "record", "", "file to record backend traffic to")
+	flag.StringVar(&trigname, "trigger", trigger.Application.String(), "trigger type (Verification, VerificationR, Application or ApplicationR)")
+	flag.StringVar(&hextx, "tx", "", "transaction hex to use as script container")
+	flag.BoolVar(&verify, "verify", false, "verify witnesses of the transaction given with -tx instead of running a script")
+	flag.StringVar(&replay, "replay", "", "file to replay backend traffic from instead of rpc")
+	flag.StringVar(&serve, "serve", "", "address to serve invokescript and invokefunction JSON-RPC requests on")
+	flag.StringVar(&contract, "contract", "", "contract to invoke instead of running a script, parameters follow the flags")
+	flag.StringVar(&operation, "operation", "", "operation to invoke with -contract")
+	flag.StringVar(&jsonparams, "params", "", "JSON array of parameters to invoke -contract with")
+	flag.StringVar(&batch, "batch", "", "JSONL file to read invocation requests from (- for stdin)")
+	flag.IntVar(&workers, "workers", runtime.NumCPU(), "number of concurrent invocations in batch mode")
+	flag.Int64Var(&pinheight, "height", -1, "block height to perform the invocation at instead of the current one")
+	flag.Int64Var(&blocktime, "time", -1, "timestamp of the block being persisted (defaults to the next block one)")
+}
+
+// setup parses the flags and sets the backend up. Failures are reported the
+// same way invocation ones are.
+func setup() {
 	flag.Parse()
+	if pinheight > math.MaxUint32 || blocktime > math.MaxUint32 {
+		exitWithError(newInvocationError(errors.New("height and time must fit uint32"), errorKindRequest))
+	}
+
+	var err error
+	switch {
+	case len(snapshot) > 0:
+		backend, err = chain.NewFixtureBackend(snapshot)
+	case len(replay) > 0:
+		backend, err = chain.NewReplayBackend(replay)
+	default:
+		var client *rpc.Client
+		client, err = rpc.New(strings.Split(rpcaddr, ","), rpcopts)
+		if neocli {
+			backend = chain.NewNeoCLIBackend(client)
+		} else {
+			backend = chain.NewRPCBackend(client)
+		}
+		if err == nil && len(cachedir) > 0 {
+			backend, err = chain.NewDiskCache(backend, cachedir, cachesize)
+		}
+	}
+	if err != nil {
+		exitWithError(newInvocationError(err, errorKindRequest))
+	}
+	if len(record) > 0 {
+		recorder = chain.NewRecorder(backend)
+		backend = recorder
+	}
+}
 
-	storage = make(map[string][]byte)
-	witnesses = make(map[util.Uint160]struct{})
-	for _, v := range strings.Split(wits, ":") {
+// newInvocation prepares an invocation with the given parameters. It's
+// performed at the current height unless another one is requested.
+func newInvocation(req *invokeRequest) (*invocation, error) {
+	inv := &invocation{
+		gaslimit:      req.GasLimit,
+		contracts:     make(map[util.Uint160]*state.Contract),
+		assets:        make(map[util.Uint256]*state.Asset),
+		notifications: []state.NotificationEvent{},
+		logs:          []logMessage{},
+		witnesses:     make(map[util.Uint160]struct{}),
+	}
+	if inv.gaslimit == 0 {
+		inv.gaslimit = defaultGasLimit
+	}
+	var err error
+	inv.trig = trigger.Application
+	if len(req.Trigger) > 0 {
+		inv.trig, err = trigger.FromString(req.Trigger)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range req.Witnesses {
 		if len(v) == 0 {
 			continue
 		}
-		sc, err := util.Uint160DecodeStringBE(v)
+		sc, err := decodeScriptHash(v)
 		if err != nil {
-			log.Fatalln(err)
+			return nil, err
 		}
-		witnesses[sc] = struct{}{}
+		inv.witnesses[sc] = struct{}{}
 	}
-
-	data := make(map[string]interface{})
-	data["jsonrpc"] = "2.0"
-	data["method"] = "GetCurrentBlockHeightInUint64"
-	data["params"] = map[string]interface{}{}
-	data["id"] = 1
-	bytesData, err := json.Marshal(data)
+	inv.height, err = backend.GetHeight()
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
 	}
-	resp, err := http.Post(rpcaddr, "application/json", bytes.NewReader(bytesData))
-	if err != nil {
-		log.Fatalln(err)
+	if req.Height != nil {
+		if *req.Height > inv.height {
+			return nil, fmt.Errorf("height %d is above the current one %d", *req.Height, inv.height)
+		}
+		if *req.Height < inv.height && !backend.HasHistory() {
+			return nil, fmt.Errorf("backend serves current state only, it can't be used at height %d", *req.Height)
+		}
+		inv.height = *req.Height
+		inv.pinned = true
 	}
-	defer resp.Body.Close()
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(&data)
+	inv.time = req.Time
+	log.Println("[HEIGHT]", inv.height)
+	inv.store = chain.NewOverlay(backend, inv.height)
+	inv.script, err = hex.DecodeString(req.Script)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
 	}
-	log.Println(data)
-	height = uint32(data["result"].(float64))
-	script, err = hex.DecodeString(hexscript)
-	if err != nil {
-		log.Fatalln(err)
+	if len(req.Tx) > 0 {
+		txbytes, err := hex.DecodeString(req.Tx)
+		if err != nil {
+			return nil, err
+		}
+		r := io.NewBinReaderFromBuf(txbytes)
+		inv.container = new(transaction.Transaction)
+		inv.container.DecodeBinary(r)
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		log.Println("[TX]", inv.container.Hash().StringLE())
+		// The container is witnessed by everyone it's to be verified by.
+		hashes, err := inv.getScriptHashesForVerifying(inv.container)
+		if err != nil {
+			return nil, err
+		}
+		for _, hash := range hashes {
+			inv.witnesses[hash] = struct{}{}
+		}
+		// Invocation transaction script is run unless another one is given.
+		if tx, ok := inv.container.Data.(*transaction.InvocationTX); ok && len(inv.script) == 0 {
+			inv.script = tx.Script
+		}
 	}
+	return inv, nil
 }
 
-var script []byte
+var hexscript string
 var gaslimit int64
-var storage map[string][]byte
-var witnesses map[util.Uint160]struct{}
-var height uint32
+var wits string
+var hextx string
+var trigname string
+var verify bool
 var rpcaddr string
+var rpcopts rpc.Options
+var neocli bool
+var cachedir string
+var cachesize int64
+var snapshot string
+var record string
+var replay string
+var serve string
+var batch string
+var contract string
+var operation string
+var jsonparams string
+var workers int
+var pinheight int64
+var blocktime int64
+var recorder *chain.Recorder
+var backend chain.Backend
 
-func mOK(v interface{}, ok bool) interface{} {
-	if ok == false {
-		panic(ok)
-	}
-	return v
+// invocation is the state of a single script run.
+type invocation struct {
+	script        []byte
+	gaslimit      int64
+	trig          trigger.Type
+	height        uint32
+	pinned        bool
+	time          *uint32
+	container     *transaction.Transaction
+	witnesses     map[util.Uint160]struct{}
+	contracts     map[util.Uint160]*state.Contract
+	assets        map[util.Uint256]*state.Asset
+	store         *chain.Overlay
+	notifications []state.NotificationEvent
+	logs          []logMessage
 }
 
-func mERR(v interface{}, err error) interface{} {
-	if err != nil {
-		panic(err)
-	}
-	return v
+// invokeRequest is a set of invocation parameters. Witnesses are BE script
+// hashes, the ones the Tx is verified by are added to them. The height
+// defaults to the current one, past ones need a backend with history. Time is
+// the timestamp of the block being persisted, it defaults to the one of the
+// block following the height.
+type invokeRequest struct {
+	Script    string   `json:"script"`
+	Witnesses []string `json:"witnesses"`
+	Height    *uint32  `json:"height"`
+	Time      *uint32  `json:"time"`
+	GasLimit  int64    `json:"gaslimit"`
+	Trigger   string   `json:"trigger"`
+	Tx        string   `json:"tx"`
 }
 
-func mCHK(err error) {
-	if err != nil {
-		panic(err)
+// invokeFunctionRequest is a set of invokefunction parameters.
+type invokeFunctionRequest struct {
+	invokeRequest
+	Contract  string                    `json:"contract"`
+	Operation string                    `json:"operation"`
+	Params    []smartcontract.Parameter `json:"params"`
+}
+
+// rpcRequest is a JSON-RPC request accepted in serve mode.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// rpcResponse is a JSON-RPC response returned in serve mode.
+type rpcResponse struct {
+	JSONRPC string                 `json:"jsonrpc"`
+	ID      json.RawMessage        `json:"id"`
+	Result  map[string]interface{} `json:"result,omitempty"`
+	Error   *rpcError              `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC error object.
+type rpcError struct {
+	Code    int              `json:"code"`
+	Message string           `json:"message"`
+	Data    *invocationError `json:"data,omitempty"`
+}
+
+// invocationError describes why an invocation has failed.
+type invocationError struct {
+	// Kind is one of errorKind* constants.
+	Kind string `json:"kind"`
+	// Syscall is the interop the script has failed in.
+	Syscall string `json:"syscall,omitempty"`
+	// Lookup is the backend request that has failed.
+	Lookup  string `json:"lookup,omitempty"`
+	Message string `json:"message"`
+}
+
+// stateChangingSyscalls are not allowed to be used with verification
+// triggers.
+var stateChangingSyscalls = map[uint32]struct{}{
+	vm.InteropNameToID([]byte("System.Contract.Destroy")): {},
+	vm.InteropNameToID([]byte("System.Storage.Delete")):   {},
+	vm.InteropNameToID([]byte("System.Storage.Put")):      {},
+	vm.InteropNameToID([]byte("System.Storage.PutEx")):    {},
+	vm.InteropNameToID([]byte("Neo.Asset.Create")):        {},
+	vm.InteropNameToID([]byte("Neo.Asset.Renew")):         {},
+	vm.InteropNameToID([]byte("Neo.Contract.Create")):     {},
+	vm.InteropNameToID([]byte("Neo.Contract.Destroy")):    {},
+	vm.InteropNameToID([]byte("Neo.Contract.Migrate")):    {},
+	vm.InteropNameToID([]byte("Neo.Storage.Delete")):      {},
+	vm.InteropNameToID([]byte("Neo.Storage.Put")):         {},
+}
+
+func isVerification(t trigger.Type) bool {
+	return t == trigger.Verification || t == trigger.VerificationR
+}
+
+// isVerified checks that the VM has halted with a single true value on the
+// stack, which is what verification scripts are required to return.
+func isVerified(v *vm.VM) bool {
+	if !v.HasHalted() || v.Estack().Len() != 1 {
+		return false
 	}
+	ok, err := v.Estack().Peek(0).TryBool()
+	return err == nil && ok
 }
 
 // FROM NSPCC CODE ...
 
 const interopGasRatio = 100000
 
+// defaultGasLimit is the GAS limit of an invocation unless another one is
+// requested.
+const defaultGasLimit = 50000000000
+
+// secondsPerBlock is the expected time between blocks.
+const secondsPerBlock = 15
+
+// serveCacheSize is the number of blocks and contracts kept in memory in
+// serve and batch modes.
+const serveCacheSize = 10000
+
+// recordInterval is the interval the recording is saved at in serve mode.
+const recordInterval = 10 * time.Second
+
+// defaultCacheSize is the default size limit of the -cache directory.
+const defaultCacheSize = 256 << 20
+
+// Invocation error kinds.
+const (
+	// errorKindRequest is a bad invocation request.
+	errorKindRequest = "request"
+	// errorKindFault is a script failure.
+	errorKindFault = "fault"
+	// errorKindBackend is a failure to get chain data.
+	errorKindBackend = "backend"
+)
+
+// maxBatchLineSize is the maximum size of a single batch request.
+const maxBatchLineSize = 16 * 1024 * 1024
+
+// assetRenewalBlocks is the number of blocks an asset is registered for per
+// year of Neo.Asset.Create and Neo.Asset.Renew.
+const assetRenewalBlocks = 2000000
+
+// storageFlags are the flags of System.Storage.PutEx.
+type storageFlags byte
+
+// storageConstant marks a storage item that can't be changed or deleted.
+const storageConstant storageFlags = 0x01
+
+// verificationGasLimit is the amount of free GAS C# node gives to each
+// witness verification.
+var verificationGasLimit = util.Fixed8FromInt64(10)
+
 type StorageContext struct {
 	ScriptHash util.Uint160
 	ReadOnly   bool
 }
 
-func getBlockHashFromElement(element *vm.Element) (util.Uint256, error) {
+// logMessage is a message emitted by Runtime.Log.
+type logMessage struct {
+	ScriptHash util.Uint160 `json:"contract"`
+	Message    string       `json:"message"`
+}
+
+// nep5Transfer is a NEP-5 transfer decoded from the notification.
+type nep5Transfer struct {
+	Asset  util.Uint160 `json:"asset"`
+	From   string       `json:"from,omitempty"`
+	To     string       `json:"to,omitempty"`
+	Amount string       `json:"amount"`
+}
+
+// nep5BalanceChange is a net change of the address balance of one NEP-5
+// asset.
+type nep5BalanceChange struct {
+	Address string       `json:"address"`
+	Asset   util.Uint160 `json:"asset"`
+	Amount  string       `json:"amount"`
+}
+
+// contractStorageChanges is a list of storage changes made by one contract.
+type contractStorageChanges struct {
+	Contract util.Uint160         `json:"contract"`
+	Changes  []storageChangeState `json:"changes"`
+}
+
+// storageChangeState describes how a storage item was changed by the
+// invocation.
+type storageChangeState struct {
+	Key      string `json:"key"`
+	OldValue string `json:"old_value"`
+	NewValue string `json:"new_value"`
+	State    string `json:"state"`
+}
+
+// witnessResult is a result of a single witness verification.
+type witnessResult struct {
+	ScriptHash  util.Uint160 `json:"script_hash"`
+	State       string       `json:"state,omitempty"`
+	GasConsumed util.Fixed8  `json:"gas_consumed"`
+	Verified    bool         `json:"verified"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// getNEP5Transfers decodes NEP-5 transfers from the given notifications and
+// computes net balance changes they make. Minting and burning (transfers
+// from or to an empty address) only change the balance of the other party.
+func getNEP5Transfers(events []state.NotificationEvent, height uint32) ([]nep5Transfer, []nep5BalanceChange) {
+	type balanceKey struct {
+		address util.Uint160
+		asset   util.Uint160
+	}
+	transfers := []nep5Transfer{}
+	deltas := make(map[balanceKey]*big.Int)
+	addDelta := func(k balanceKey, amount *big.Int) {
+		if d, ok := deltas[k]; ok {
+			d.Add(d, amount)
+		} else {
+			deltas[k] = new(big.Int).Set(amount)
+		}
+	}
+	for i := range events {
+		tr, err := state.NEP5TransferFromNotification(events[i], util.Uint256{}, height, 0, uint32(i))
+		if err != nil {
+			continue
+		}
+		amount := nep5Amount(events[i])
+		t := nep5Transfer{
+			Asset:  tr.Asset,
+			Amount: amount.String(),
+		}
+		if !tr.From.Equals(util.Uint160{}) {
+			t.From = address.Uint160ToString(tr.From)
+			addDelta(balanceKey{tr.From, tr.Asset}, new(big.Int).Neg(amount))
+		}
+		if !tr.To.Equals(util.Uint160{}) {
+			t.To = address.Uint160ToString(tr.To)
+			addDelta(balanceKey{tr.To, tr.Asset}, amount)
+		}
+		transfers = append(transfers, t)
+	}
+
+	balances := []nep5BalanceChange{}
+	keys := make([]balanceKey, 0, len(deltas))
+	for k, d := range deltas {
+		if d.Sign() != 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].address.Equals(keys[j].address) {
+			return keys[i].address.Less(keys[j].address)
+		}
+		return keys[i].asset.Less(keys[j].asset)
+	})
+	for _, k := range keys {
+		balances = append(balances, nep5BalanceChange{
+			Address: address.Uint160ToString(k.address),
+			Asset:   k.asset,
+			Amount:  deltas[k].String(),
+		})
+	}
+	return transfers, balances
+}
+
+// nep5Amount returns the amount of the NEP-5 transfer notification.
+// NEP5Transfer.Amount can't be used as it's truncated to int64.
+func nep5Amount(ne state.NotificationEvent) *big.Int {
+	amount := ne.Item.Value().([]vm.StackItem)[3].Value()
+	if n, ok := amount.(*big.Int); ok {
+		return n
+	}
+	return emit.BytesToInt(amount.([]byte))
+}
+
+// getContract returns the contract with the given hash taking local
+// deployments into account.
+func (inv *invocation) getContract(hash util.Uint160) (*state.Contract, error) {
+	if cs, ok := inv.contracts[hash]; ok {
+		if cs == nil {
+			return nil, fmt.Errorf("contract %s %w", hash.StringBE(), chain.ErrNotFound)
+		}
+		return cs, nil
+	}
+	return backend.GetContract(hash, inv.height)
+}
+
+// deployContract adds the contract to the local state unless it's already
+// deployed, in which case the existing one is returned.
+func (inv *invocation) deployContract(cs *state.Contract) (*state.Contract, bool, error) {
+	hash := cs.ScriptHash()
+	existing, err := inv.getContract(hash)
+	if err == nil {
+		return existing, false, nil
+	}
+	if !errors.Is(err, chain.ErrNotFound) {
+		return nil, false, err
+	}
+	inv.contracts[hash] = cs
+	log.Println("[DEPLOY]", hash.StringBE())
+	return cs, true, nil
+}
+
+// destroyContract removes the contract along with its storage from the local
+// state. Missing contracts are ignored.
+func (inv *invocation) destroyContract(hash util.Uint160) error {
+	cs, err := inv.getContract(hash)
+	if errors.Is(err, chain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	inv.contracts[hash] = nil
+	log.Println("[DESTROY]", hash.StringBE())
+	if cs.HasStorage() {
+		kvs, err := inv.store.Find(hash, nil)
+		if err != nil {
+			return err
+		}
+		for _, kv := range kvs {
+			inv.store.Delete(hash, kv.Key)
+		}
+	}
+	return nil
+}
+
+// popContractFromVM creates a new contract from Neo.Contract.Create (or
+// Migrate) parameters.
+func popContractFromVM(v *vm.VM) (*state.Contract, error) {
+	script := v.Estack().Pop().Bytes()
+	if len(script) > 1024*1024 {
+		return nil, errors.New("the script is too big")
+	}
+	paramBytes := v.Estack().Pop().Bytes()
+	if len(paramBytes) > 252 {
+		return nil, errors.New("too many parameters")
+	}
+	paramList := make([]smartcontract.ParamType, len(paramBytes))
+	for k, p := range paramBytes {
+		paramList[k] = smartcontract.ParamType(p)
+	}
+	retType := smartcontract.ParamType(v.Estack().Pop().BigInt().Int64())
+	properties := smartcontract.PropertyState(v.Estack().Pop().BigInt().Int64())
+	var strs [4]string
+	for i := range strs {
+		b := v.Estack().Pop().Bytes()
+		if len(b) > 252 {
+			return nil, errors.New("too big string parameter")
+		}
+		strs[i] = string(b)
+	}
+	desc := v.Estack().Pop().Bytes()
+	if len(desc) > 65536 {
+		return nil, errors.New("too big description")
+	}
+	return &state.Contract{
+		Script:      script,
+		ParamList:   paramList,
+		ReturnType:  retType,
+		Properties:  properties,
+		Name:        strs[0],
+		CodeVersion: strs[1],
+		Author:      strs[2],
+		Email:       strs[3],
+		Description: string(desc),
+	}, nil
+}
+
+// getAsset returns the asset with the given ID taking local registrations
+// into account.
+func (inv *invocation) getAsset(id util.Uint256) (*state.Asset, error) {
+	if asset, ok := inv.assets[id]; ok {
+		return asset, nil
+	}
+	return backend.GetAsset(id, inv.height)
+}
+
+// popAssetFromVM creates a new asset from Neo.Asset.Create parameters the same
+// way C# node does it, the ID of the asset is the hash of the script container.
+func (inv *invocation) popAssetFromVM(v *vm.VM) (*state.Asset, error) {
+	if inv.trig != trigger.Application {
+		return nil, fmt.Errorf("can't create assets with %s trigger", inv.trig)
+	}
+	if inv.container == nil || inv.container.Type != transaction.InvocationType {
+		return nil, errors.New("invocation transaction is required to create assets")
+	}
+	assetType := transaction.AssetType(byte(v.Estack().Pop().BigInt().Int64()))
+	switch assetType {
+	case transaction.Currency, transaction.Share, transaction.Invoice, transaction.Token:
+	default:
+		return nil, fmt.Errorf("invalid asset type %d", assetType)
+	}
+	name := v.Estack().Pop().Bytes()
+	if len(name) > 1024 {
+		return nil, errors.New("too big name")
+	}
+	amount := util.Fixed8(v.Estack().Pop().BigInt().Int64())
+	if amount == 0 || amount < -1 {
+		return nil, errors.New("invalid amount")
+	}
+	if assetType == transaction.Invoice && amount != -1 {
+		return nil, errors.New("invoice assets must have unlimited amount")
+	}
+	precision := byte(v.Estack().Pop().BigInt().Int64())
+	if precision > 8 {
+		return nil, errors.New("invalid precision")
+	}
+	if assetType == transaction.Share && precision != 0 {
+		return nil, errors.New("share assets must have zero precision")
+	}
+	if amount != -1 && int64(amount)%int64(math.Pow10(8-int(precision))) != 0 {
+		return nil, errors.New("amount doesn't match precision")
+	}
+	owner := &keys.PublicKey{}
+	if err := owner.DecodeBytes(v.Estack().Pop().Bytes()); err != nil {
+		return nil, err
+	}
+	if owner.IsInfinity() {
+		return nil, errors.New("invalid owner key")
+	}
+	if _, ok := inv.witnesses[owner.GetScriptHash()]; !ok {
+		return nil, errors.New("asset owner witness check failed")
+	}
+	admin, err := util.Uint160DecodeBytesBE(v.Estack().Pop().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	issuer, err := util.Uint160DecodeBytesBE(v.Estack().Pop().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return &state.Asset{
+		ID:         inv.container.Hash(),
+		AssetType:  assetType,
+		Name:       string(name),
+		Amount:     amount,
+		Precision:  precision,
+		Owner:      *owner,
+		Admin:      admin,
+		Issuer:     issuer,
+		Expiration: inv.height + 1 + assetRenewalBlocks,
+	}, nil
+}
+
+// checkStorageWrite returns an error if the key can't be changed within the
+// given storage context.
+func (inv *invocation) checkStorageWrite(stc *StorageContext, key []byte) error {
+	if stc.ReadOnly {
+		return errors.New("StorageContext is read only")
+	}
+	si, err := inv.store.Get(stc.ScriptHash, key)
+	if errors.Is(err, chain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if si.IsConst {
+		return errors.New("storage item is constant")
+	}
+	return nil
+}
+
+// getStorageChanges compares local writes with the backend storage and
+// returns them grouped by contract. Writes that don't change anything are
+// omitted.
+func (inv *invocation) getStorageChanges() ([]contractStorageChanges, error) {
+	changes, err := inv.store.Changes()
+	if err != nil {
+		return nil, err
+	}
+	res := []contractStorageChanges{}
+	for _, c := range changes {
+		var oldValue, newValue []byte
+		if c.Old != nil {
+			oldValue = c.Old.Value
+		}
+		if c.New != nil {
+			newValue = c.New.Value
+		}
+		var state string
+		switch {
+		case c.New == nil && c.Old == nil:
+			continue
+		case c.New == nil:
+			state = "Deleted"
+		case c.Old == nil:
+			state = "Added"
+		case bytes.Equal(oldValue, newValue) && c.Old.IsConst == c.New.IsConst:
+			continue
+		default:
+			state = "Changed"
+		}
+		if len(res) == 0 || !res[len(res)-1].Contract.Equals(c.ScriptHash) {
+			res = append(res, contractStorageChanges{Contract: c.ScriptHash})
+		}
+		last := &res[len(res)-1]
+		last.Changes = append(last.Changes, storageChangeState{
+			Key:      hex.EncodeToString(c.Key),
+			OldValue: hex.EncodeToString(oldValue),
+			NewValue: hex.EncodeToString(newValue),
+			State:    state,
+		})
+	}
+	return res, nil
+}
+
+func (inv *invocation) getBlockHashFromElement(element *vm.Element) (util.Uint256, error) {
 	var hash util.Uint256
 	hashbytes := element.Bytes()
 	if len(hashbytes) <= 5 {
@@ -1502,67 +2784,82 @@ func getBlockHashFromElement(element *vm.Element) (util.Uint256, error) {
 		if hashint < 0 || hashint > math.MaxUint32 {
 			return hash, errors.New("bad block index")
 		}
-		data := make(map[string]interface{})
-		data["jsonrpc"] = "2.0"
-		data["method"] = "GetBlockHashByBlockHeightInHex"
-		data["params"] = map[string]interface{}{"BlockHeight": uint32(hashint)}
-		data["id"] = 1
-		bytesData, err := json.Marshal(data)
-		if err != nil {
-			return util.Uint256{0}, err
-		}
-		resp, err := http.Post(rpcaddr, "application/json", bytes.NewReader(bytesData))
-		if err != nil {
-			return util.Uint256{0}, err
-		}
-		defer resp.Body.Close()
-		decoder := json.NewDecoder(resp.Body)
-		err = decoder.Decode(&data)
-		if err != nil {
-			return util.Uint256{0}, err
+		if hashint > int64(inv.height) {
+			return hash, fmt.Errorf("block %d %w", hashint, chain.ErrNotFound)
 		}
-		str, ok := data["result"].(string)
-		if ok == false {
-			return util.Uint256{0}, errors.New("error")
-		}
-		return util.Uint256DecodeStringBE(str)
+		return backend.GetBlockHash(uint32(hashint))
 	} else {
 		return util.Uint256DecodeBytesLE(hashbytes)
 	}
 }
 
-func getTransactionAndHeight(v *vm.VM) (*transaction.Transaction, uint32, error) {
+// getTransactionAndHeight pops a transaction hash from the stack and returns
+// the transaction unless it's added after the invocation height.
+func (inv *invocation) getTransactionAndHeight(v *vm.VM) (*transaction.Transaction, uint32, error) {
 	hashbytes := v.Estack().Pop().Bytes()
 	hash, err := util.Uint256DecodeBytesBE(hashbytes)
 	if err != nil {
 		return nil, 0, err
 	}
+	return inv.getTransaction(hash)
+}
 
-	data := make(map[string]interface{})
-	data["jsonrpc"] = "2.0"
-	data["method"] = "Data.GetTransactionByHashInHex"
-	// query json here
-	data["params"] = map[string]interface{}{"Hash": hash.StringLE()}
-	data["id"] = 1
-	bytesData, err := json.Marshal(data)
-
-	resp, err := http.Post(rpcaddr, "application/json", bytes.NewReader(bytesData))
+// getTransaction returns the transaction unless it's added after the
+// invocation height. Transactions of unknown height can't be checked against
+// the height, so they're only returned if it's not pinned.
+func (inv *invocation) getTransaction(hash util.Uint256) (*transaction.Transaction, uint32, error) {
+	tx, height, err := backend.GetTransaction(hash)
 	if err != nil {
 		return nil, 0, err
 	}
-	defer resp.Body.Close()
+	if height == chain.UnknownHeight {
+		if inv.pinned {
+			return nil, 0, fmt.Errorf("transaction %s height is unknown, it can't be used at a pinned height", hash.StringLE())
+		}
+		return tx, height, nil
+	}
+	if height > inv.height {
+		return nil, 0, fmt.Errorf("transaction %s %w", hash.StringLE(), chain.ErrNotFound)
+	}
+	return tx, height, nil
+}
 
-	tx := new(transaction.Transaction)
-	jsonbytes, err := json.Marshal(data["result"])
+// getBlock returns the block unless it's added after the invocation height.
+func (inv *invocation) getBlock(hash util.Uint256) (*block.Block, error) {
+	blk, err := backend.GetBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+	if blk.Index > inv.height {
+		return nil, fmt.Errorf("block %s %w", hash.StringLE(), chain.ErrNotFound)
+	}
+	return blk, nil
+}
 
+// getHeader returns the header unless it's added after the invocation height.
+func (inv *invocation) getHeader(hash util.Uint256) (*block.Header, error) {
+	hd, err := backend.GetHeader(hash)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
-	err = tx.UnmarshalJSON(jsonbytes)
+	if hd.Index > inv.height {
+		return nil, fmt.Errorf("header %s %w", hash.StringLE(), chain.ErrNotFound)
+	}
+	return hd, nil
+}
+
+// getTime returns the timestamp of the block being persisted. Unless it's
+// given, the block is assumed to follow the one at the invocation height in
+// due time, like C# node does for invokescript.
+func (inv *invocation) getTime() (uint32, error) {
+	if inv.time != nil {
+		return *inv.time, nil
+	}
+	hd, err := backend.GetHeaderByIndex(inv.height)
 	if err != nil {
-		return nil, 0, err
+		return 0, err
 	}
-	return tx, 0, err
+	return hd.Timestamp + secondsPerBlock, nil
 }
 
 func getContextScriptHash(v *vm.VM, n int) util.Uint160 {
@@ -1577,6 +2874,8 @@ func pushContextScriptHash(v *vm.VM, n int) error {
 	return nil
 }
 
+// popHeaderFromVM pops a header or a block from the stack and returns its
+// header.
 func popHeaderFromVM(v *vm.VM) (*block.Header, error) {
 	iface := v.Estack().Pop().Value()
 	header, ok := iface.(*block.Header)
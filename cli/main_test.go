@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/nspcc-dev/neo-go/pkg/chain"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/hash"
+	"github.com/nspcc-dev/neo-go/pkg/io"
+	"github.com/nspcc-dev/neo-go/pkg/smartcontract"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/nspcc-dev/neo-go/pkg/vm/emit"
+	"github.com/nspcc-dev/neo-go/pkg/vm/opcode"
+)
+
+// checkWitnessScript returns a script checking witnesses of the given hashes.
+func checkWitnessScript(hashes ...util.Uint160) string {
+	w := io.NewBufBinWriter()
+	for _, hash := range hashes {
+		emit.Bytes(w.BinWriter, hash.BytesBE())
+		emit.Syscall(w.BinWriter, "Neo.Runtime.CheckWitness")
+	}
+	return hex.EncodeToString(w.Bytes())
+}
+
+func TestCheckWitnessContainer(t *testing.T) {
+	contractHash, attrHash, other := util.Uint160{1, 2, 3}, util.Uint160{4, 5, 6}, util.Uint160{7, 8, 9}
+
+	// An output owned by a contract account is spent by the container.
+	prev := transaction.NewContractTX()
+	prev.AddOutput(transaction.NewOutput(util.Uint256{1}, 5, contractHash))
+	container := transaction.NewInvocationTX([]byte{0x51}, 0)
+	container.AddInput(&transaction.Input{PrevHash: prev.Hash(), PrevIndex: 0})
+	container.Attributes = append(container.Attributes, transaction.Attribute{Usage: transaction.Script, Data: attrHash.BytesBE()})
+	// Contract accounts have no verification script in the witness.
+	container.Scripts = append(container.Scripts, transaction.Witness{})
+
+	var err error
+	backend, err = chain.NewFixtureBackendFromSnapshot(&chain.Snapshot{
+		Version:      chain.SnapshotVersion,
+		Height:       10,
+		Transactions: []chain.SnapshotTx{{Tx: hex.EncodeToString(prev.Bytes()), Height: 5}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { backend = nil }()
+
+	var testCases = []struct {
+		name     string
+		req      invokeRequest
+		expected []bool
+	}{
+		{
+			name:     "container",
+			req:      invokeRequest{Tx: hex.EncodeToString(container.Bytes())},
+			expected: []bool{true, true, false},
+		},
+		{
+			name:     "container and explicit witness",
+			req:      invokeRequest{Tx: hex.EncodeToString(container.Bytes()), Witnesses: []string{other.StringBE()}},
+			expected: []bool{true, true, true},
+		},
+		{
+			name:     "no container",
+			req:      invokeRequest{},
+			expected: []bool{false, false, false},
+		},
+	}
+	for _, tc := range testCases {
+		tc.req.Script = checkWitnessScript(contractHash, attrHash, other)
+		inv, err := newInvocation(&tc.req)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		res, err := inv.run()
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		stack := res["stack"].([]smartcontract.Parameter)
+		if len(stack) != len(tc.expected) {
+			t.Fatalf("%s: expected %d stack items, got %d", tc.name, len(tc.expected), len(stack))
+		}
+		for i, expected := range tc.expected {
+			if actual := stack[i].Value; actual != expected {
+				t.Errorf("%s: expected %t for witness %d, got %v", tc.name, expected, i, actual)
+			}
+		}
+	}
+}
+
+func TestPinnedHeightWithoutHistory(t *testing.T) {
+	var err error
+	backend, err = chain.NewFixtureBackendFromSnapshot(&chain.Snapshot{Version: chain.SnapshotVersion, Height: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { backend = nil }()
+
+	var testCases = []struct {
+		height uint32
+		ok     bool
+	}{
+		{10, true},
+		{9, false},
+		{11, false},
+	}
+	for _, tc := range testCases {
+		height := tc.height
+		_, err := newInvocation(&invokeRequest{Script: "51", Height: &height})
+		if ok := err == nil; ok != tc.ok {
+			t.Errorf("height %d: expected success to be %t, got %v", tc.height, tc.ok, err)
+		}
+	}
+}
+
+func TestContractGetScriptIsPayable(t *testing.T) {
+	payable := &state.Contract{Script: []byte{0x51, 0x66}, Properties: smartcontract.IsPayable}
+	plain := &state.Contract{Script: []byte{0x52, 0x66}}
+	contracts := make(map[string]string)
+	for _, cs := range []*state.Contract{payable, plain} {
+		w := io.NewBufBinWriter()
+		cs.EncodeBinary(w.BinWriter)
+		contracts[cs.ScriptHash().StringBE()] = hex.EncodeToString(w.Bytes())
+	}
+	var err error
+	backend, err = chain.NewFixtureBackendFromSnapshot(&chain.Snapshot{
+		Version:   chain.SnapshotVersion,
+		Contracts: contracts,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { backend = nil }()
+
+	for _, cs := range []*state.Contract{payable, plain} {
+		w := io.NewBufBinWriter()
+		emit.Bytes(w.BinWriter, cs.ScriptHash().BytesBE())
+		emit.Syscall(w.BinWriter, "Neo.Blockchain.GetContract")
+		emit.Opcode(w.BinWriter, opcode.DUP)
+		emit.Syscall(w.BinWriter, "Neo.Contract.GetScript")
+		emit.Opcode(w.BinWriter, opcode.SWAP)
+		emit.Syscall(w.BinWriter, "Neo.Contract.IsPayable")
+		inv, err := newInvocation(&invokeRequest{Script: hex.EncodeToString(w.Bytes())})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := inv.run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stack := res["stack"].([]smartcontract.Parameter)
+		if len(stack) != 2 {
+			t.Fatalf("expected 2 stack items, got %d", len(stack))
+		}
+		if script, ok := stack[0].Value.([]byte); !ok || string(script) != string(cs.Script) {
+			t.Errorf("expected script %x, got %v", cs.Script, stack[0].Value)
+		}
+		if stack[1].Value != cs.IsPayable() {
+			t.Errorf("expected payable %t, got %v", cs.IsPayable(), stack[1].Value)
+		}
+	}
+}
+
+func TestApplicationLogJSON(t *testing.T) {
+	var err error
+	backend, err = chain.NewFixtureBackendFromSnapshot(&chain.Snapshot{Version: chain.SnapshotVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { backend = nil }()
+
+	w := io.NewBufBinWriter()
+	emit.String(w.BinWriter, "event")
+	emit.Syscall(w.BinWriter, "Neo.Runtime.Notify")
+	emit.Int(w.BinWriter, 42)
+	script := w.Bytes()
+	inv, err := newInvocation(&invokeRequest{Script: hex.EncodeToString(script)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := inv.run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual struct {
+		ApplicationLog struct {
+			Trigger       string `json:"trigger"`
+			VMState       string `json:"vmstate"`
+			Notifications []struct {
+				Contract string `json:"contract"`
+				State    struct {
+					Type  string `json:"type"`
+					Value string `json:"value"`
+				} `json:"state"`
+			} `json:"notifications"`
+		} `json:"application_log"`
+	}
+	if err := json.Unmarshal(data, &actual); err != nil {
+		t.Fatal(err)
+	}
+	al := actual.ApplicationLog
+	if al.Trigger != "Application" || al.VMState != "HALT" {
+		t.Errorf("expected Application and HALT, got %s and %s", al.Trigger, al.VMState)
+	}
+	if len(al.Notifications) != 1 {
+		t.Fatalf("expected 1 notification, got %s", data)
+	}
+	ne := al.Notifications[0]
+	if ne.Contract != "0x"+hash.Hash160(script).StringLE() || ne.State.Type != "ByteArray" || ne.State.Value != hex.EncodeToString([]byte("event")) {
+		t.Errorf("unexpected notification %+v", ne)
+	}
+}
@@ -0,0 +1,96 @@
+package chain
+
+import (
+	"encoding/hex"
+	"errors"
+	"math"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neo-go/pkg/io"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// ErrNotFound is returned (possibly wrapped) by Backend when the requested
+// entity doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// UnknownHeight is returned by Backend as the height of transactions when it
+// can't tell it.
+const UnknownHeight = math.MaxUint32
+
+// Error is returned by Backend when chain data can't be retrieved or decoded,
+// as opposed to the data not existing (see ErrNotFound).
+type Error struct {
+	// Method is the request that failed.
+	Method string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.Method + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Backend is a source of chain data used by the interop handlers. Contract
+// and storage lookups take the height they should be performed at, so that
+// one Backend can serve invocations pinned to different blocks.
+type Backend interface {
+	// GetHeight returns the current height of the chain.
+	GetHeight() (uint32, error)
+	// GetBlockHash returns the hash of the block with the given index.
+	GetBlockHash(index uint32) (util.Uint256, error)
+	// GetBlock returns the block with the given hash.
+	GetBlock(hash util.Uint256) (*block.Block, error)
+	// GetHeader returns the header of the block with the given hash.
+	GetHeader(hash util.Uint256) (*block.Header, error)
+	// GetHeaderByIndex returns the header of the block with the given index.
+	GetHeaderByIndex(index uint32) (*block.Header, error)
+	// GetTransaction returns the transaction with the given hash along with
+	// the height of the block it's included in (or UnknownHeight).
+	GetTransaction(hash util.Uint256) (*transaction.Transaction, uint32, error)
+	// GetContract returns the contract deployed with the given script hash
+	// at the given height.
+	GetContract(hash util.Uint160, height uint32) (*state.Contract, error)
+	// GetStorage returns the item stored by the contract under the given key
+	// at the given height.
+	GetStorage(hash util.Uint160, key []byte, height uint32) (*state.StorageItem, error)
+	// FindStorage returns all items stored by the contract under keys with
+	// the given prefix at the given height. Items are returned in no
+	// particular order.
+	FindStorage(hash util.Uint160, prefix []byte, height uint32) ([]KeyValue, error)
+	// GetAccount returns the state of the account with the given script hash
+	// at the given height.
+	GetAccount(hash util.Uint160, height uint32) (*state.Account, error)
+	// GetAsset returns the state of the asset with the given ID at the given
+	// height.
+	GetAsset(id util.Uint256, height uint32) (*state.Asset, error)
+	// GetValidators returns public keys of the validators at the given
+	// height.
+	GetValidators(height uint32) (keys.PublicKeys, error)
+	// HasHistory tells whether lookups honour the height given, backends
+	// serving the current state only ignore it.
+	HasHistory() bool
+}
+
+// decodeBinary decodes b into the given Serializable.
+func decodeBinary(b []byte, s io.Serializable) error {
+	r := io.NewBinReaderFromBuf(b)
+	s.DecodeBinary(r)
+	return r.Err
+}
+
+// decodeHex decodes hex-encoded string into the given Serializable.
+func decodeHex(str string, s io.Serializable) error {
+	b, err := hex.DecodeString(str)
+	if err != nil {
+		return err
+	}
+	return decodeBinary(b, s)
+}
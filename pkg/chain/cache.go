@@ -0,0 +1,142 @@
+package chain
+
+import (
+	"sync"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// Cache is a Backend wrapper keeping immutable entities (blocks, headers,
+// transactions and contracts at a given height) in memory, so that they're
+// fetched once for all invocations sharing it. Once the limit is reached the
+// cache is dropped and filled anew.
+type Cache struct {
+	Backend
+
+	limit int
+	lock  sync.Mutex
+	items map[string]interface{}
+}
+
+// cachedTransaction is a transaction along with its block height.
+type cachedTransaction struct {
+	tx     *transaction.Transaction
+	height uint32
+}
+
+// NewCache returns a new Cache wrapping the given Backend and holding at most
+// limit entities.
+func NewCache(b Backend, limit int) *Cache {
+	return &Cache{
+		Backend: b,
+		limit:   limit,
+		items:   make(map[string]interface{}),
+	}
+}
+
+// GetBlockHash implements Backend interface.
+func (c *Cache) GetBlockHash(index uint32) (util.Uint256, error) {
+	k := "blockhash/" + formatIndex(index)
+	if v, ok := c.get(k); ok {
+		return v.(util.Uint256), nil
+	}
+	hash, err := c.Backend.GetBlockHash(index)
+	if err != nil {
+		return hash, err
+	}
+	c.put(k, hash)
+	return hash, nil
+}
+
+// GetBlock implements Backend interface.
+func (c *Cache) GetBlock(hash util.Uint256) (*block.Block, error) {
+	k := "block/" + hash.StringBE()
+	if v, ok := c.get(k); ok {
+		return v.(*block.Block), nil
+	}
+	b, err := c.Backend.GetBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+	c.put(k, b)
+	return b, nil
+}
+
+// GetHeader implements Backend interface.
+func (c *Cache) GetHeader(hash util.Uint256) (*block.Header, error) {
+	k := "header/" + hash.StringBE()
+	if v, ok := c.get(k); ok {
+		return v.(*block.Header), nil
+	}
+	h, err := c.Backend.GetHeader(hash)
+	if err != nil {
+		return nil, err
+	}
+	c.put(k, h)
+	return h, nil
+}
+
+// GetHeaderByIndex implements Backend interface.
+func (c *Cache) GetHeaderByIndex(index uint32) (*block.Header, error) {
+	k := "headerbyindex/" + formatIndex(index)
+	if v, ok := c.get(k); ok {
+		return v.(*block.Header), nil
+	}
+	h, err := c.Backend.GetHeaderByIndex(index)
+	if err != nil {
+		return nil, err
+	}
+	c.put(k, h)
+	return h, nil
+}
+
+// GetTransaction implements Backend interface. Transactions of unknown height
+// aren't cached, they may be in the mempool yet and get into a block later.
+func (c *Cache) GetTransaction(hash util.Uint256) (*transaction.Transaction, uint32, error) {
+	k := "tx/" + hash.StringBE()
+	if v, ok := c.get(k); ok {
+		ct := v.(cachedTransaction)
+		return ct.tx, ct.height, nil
+	}
+	tx, height, err := c.Backend.GetTransaction(hash)
+	if err != nil {
+		return nil, 0, err
+	}
+	if height != UnknownHeight {
+		c.put(k, cachedTransaction{tx: tx, height: height})
+	}
+	return tx, height, nil
+}
+
+// GetContract implements Backend interface.
+func (c *Cache) GetContract(hash util.Uint160, height uint32) (*state.Contract, error) {
+	k := "contract/" + hash.StringBE() + "/" + formatIndex(height)
+	if v, ok := c.get(k); ok {
+		return v.(*state.Contract), nil
+	}
+	cs, err := c.Backend.GetContract(hash, height)
+	if err != nil {
+		return nil, err
+	}
+	c.put(k, cs)
+	return cs, nil
+}
+
+func (c *Cache) get(k string) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	v, ok := c.items[k]
+	return v, ok
+}
+
+func (c *Cache) put(k string, v interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.items) >= c.limit {
+		c.items = make(map[string]interface{})
+	}
+	c.items[k] = v
+}
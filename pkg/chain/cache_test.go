@@ -0,0 +1,65 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// txBackend is a Backend serving transactions at fixed heights and counting
+// requests for them.
+type txBackend struct {
+	Backend
+	heights map[util.Uint256]uint32
+	calls   int
+}
+
+func (b *txBackend) GetTransaction(hash util.Uint256) (*transaction.Transaction, uint32, error) {
+	b.calls++
+	height, ok := b.heights[hash]
+	if !ok {
+		return nil, 0, ErrNotFound
+	}
+	return new(transaction.Transaction), height, nil
+}
+
+func TestCacheGetTransaction(t *testing.T) {
+	confirmed, pending := util.Uint256{1}, util.Uint256{2}
+	b := &txBackend{heights: map[util.Uint256]uint32{
+		confirmed: 10,
+		pending:   UnknownHeight,
+	}}
+	c := NewCache(b, 10)
+
+	var testCases = []struct {
+		name     string
+		hash     util.Uint256
+		height   uint32
+		requests int
+	}{
+		{"confirmed", confirmed, 10, 1},
+		{"pending", pending, UnknownHeight, 2},
+	}
+	for _, tc := range testCases {
+		b.calls = 0
+		for i := 0; i < 2; i++ {
+			_, height, err := c.GetTransaction(tc.hash)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			if height != tc.height {
+				t.Errorf("%s: expected height %d, got %d", tc.name, tc.height, height)
+			}
+		}
+		if b.calls != tc.requests {
+			t.Errorf("%s: expected %d backend requests, got %d", tc.name, tc.requests, b.calls)
+		}
+	}
+
+	// The transaction gets into a block later.
+	b.heights[pending] = 11
+	if _, height, err := c.GetTransaction(pending); err != nil || height != 11 {
+		t.Errorf("expected height 11, got %d (%v)", height, err)
+	}
+}
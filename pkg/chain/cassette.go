@@ -0,0 +1,485 @@
+package chain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neo-go/pkg/io"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// CassetteVersion is the version of cassette file format written by Recorder
+// and understood by ReplayBackend.
+const CassetteVersion = 1
+
+// Cassette is a recording of Backend requests and responses.
+type Cassette struct {
+	Version int             `json:"version"`
+	Entries []CassetteEntry `json:"entries"`
+}
+
+// CassetteEntry is a single recorded Backend request along with its
+// response. Result is a hex-encoded binary serialization of the returned
+// entity (or a BE hash/decimal number for hashes and heights).
+type CassetteEntry struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+	Result string   `json:"result,omitempty"`
+	Height uint32   `json:"height,omitempty"`
+	Error  string   `json:"error,omitempty"`
+	// ErrorKind tells ErrNotFound and Error failures from the other ones.
+	ErrorKind string `json:"error_kind,omitempty"`
+	// ErrorMethod is Error.Method of backend failures.
+	ErrorMethod string `json:"error_method,omitempty"`
+}
+
+// Cassette error kinds.
+const (
+	cassetteNotFound = "not_found"
+	cassetteBackend  = "backend"
+)
+
+// Recorder is a Backend wrapper recording all requests made through it.
+type Recorder struct {
+	Backend
+
+	lock    sync.Mutex
+	entries []CassetteEntry
+
+	saveLock sync.Mutex
+	// saved is the number of entries written by the last Save.
+	saved int
+}
+
+// ReplayBackend is a Backend serving responses from a Cassette.
+type ReplayBackend struct {
+	entries map[string]CassetteEntry
+}
+
+// NewRecorder returns a new Recorder wrapping the given Backend.
+func NewRecorder(b Backend) *Recorder {
+	return &Recorder{Backend: b}
+}
+
+// Cassette returns all requests recorded so far.
+func (r *Recorder) Cassette() *Cassette {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	entries := make([]CassetteEntry, len(r.entries))
+	copy(entries, r.entries)
+	return &Cassette{Version: CassetteVersion, Entries: entries}
+}
+
+// Save writes all requests recorded so far to the given file. The file is
+// replaced atomically and it's not rewritten unless there are new requests,
+// so Save can be called concurrently and often.
+func (r *Recorder) Save(path string) error {
+	r.saveLock.Lock()
+	defer r.saveLock.Unlock()
+	c := r.Cassette()
+	if r.saved != 0 && len(c.Entries) == r.saved {
+		return nil
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	r.saved = len(c.Entries)
+	return nil
+}
+
+func (r *Recorder) record(method string, params []string, result string, height uint32, err error) {
+	e := CassetteEntry{
+		Method: method,
+		Params: params,
+		Result: result,
+		Height: height,
+	}
+	if err != nil {
+		e.Error = err.Error()
+		var berr *Error
+		switch {
+		case errors.Is(err, ErrNotFound):
+			e.ErrorKind = cassetteNotFound
+		case errors.As(err, &berr):
+			e.ErrorKind = cassetteBackend
+			e.ErrorMethod = berr.Method
+		}
+	}
+	r.lock.Lock()
+	r.entries = append(r.entries, e)
+	r.lock.Unlock()
+}
+
+// GetHeight implements Backend interface.
+func (r *Recorder) GetHeight() (uint32, error) {
+	height, err := r.Backend.GetHeight()
+	r.record("GetHeight", nil, strconv.FormatUint(uint64(height), 10), 0, err)
+	return height, err
+}
+
+// GetBlockHash implements Backend interface.
+func (r *Recorder) GetBlockHash(index uint32) (util.Uint256, error) {
+	hash, err := r.Backend.GetBlockHash(index)
+	r.record("GetBlockHash", []string{formatIndex(index)}, hash.StringBE(), 0, err)
+	return hash, err
+}
+
+// GetBlock implements Backend interface.
+func (r *Recorder) GetBlock(hash util.Uint256) (*block.Block, error) {
+	blk, err := r.Backend.GetBlock(hash)
+	r.record("GetBlock", []string{hash.StringBE()}, encodeHex(blk, err), 0, err)
+	return blk, err
+}
+
+// GetHeader implements Backend interface.
+func (r *Recorder) GetHeader(hash util.Uint256) (*block.Header, error) {
+	hd, err := r.Backend.GetHeader(hash)
+	r.record("GetHeader", []string{hash.StringBE()}, encodeHex(hd, err), 0, err)
+	return hd, err
+}
+
+// GetHeaderByIndex implements Backend interface.
+func (r *Recorder) GetHeaderByIndex(index uint32) (*block.Header, error) {
+	hd, err := r.Backend.GetHeaderByIndex(index)
+	r.record("GetHeaderByIndex", []string{formatIndex(index)}, encodeHex(hd, err), 0, err)
+	return hd, err
+}
+
+// GetTransaction implements Backend interface.
+func (r *Recorder) GetTransaction(hash util.Uint256) (*transaction.Transaction, uint32, error) {
+	tx, height, err := r.Backend.GetTransaction(hash)
+	r.record("GetTransaction", []string{hash.StringBE()}, encodeHex(tx, err), height, err)
+	return tx, height, err
+}
+
+// GetContract implements Backend interface.
+func (r *Recorder) GetContract(hash util.Uint160, height uint32) (*state.Contract, error) {
+	cs, err := r.Backend.GetContract(hash, height)
+	r.record("GetContract", []string{hash.StringBE(), formatIndex(height)}, encodeHex(cs, err), 0, err)
+	return cs, err
+}
+
+// GetStorage implements Backend interface.
+func (r *Recorder) GetStorage(hash util.Uint160, key []byte, height uint32) (*state.StorageItem, error) {
+	si, err := r.Backend.GetStorage(hash, key, height)
+	r.record("GetStorage", []string{hash.StringBE(), hex.EncodeToString(key), formatIndex(height)}, encodeHex(si, err), 0, err)
+	return si, err
+}
+
+// FindStorage implements Backend interface.
+func (r *Recorder) FindStorage(hash util.Uint160, prefix []byte, height uint32) ([]KeyValue, error) {
+	kvs, err := r.Backend.FindStorage(hash, prefix, height)
+	items := keyValues(kvs)
+	r.record("FindStorage", []string{hash.StringBE(), hex.EncodeToString(prefix), formatIndex(height)}, encodeHex(&items, err), 0, err)
+	return kvs, err
+}
+
+// GetAccount implements Backend interface.
+func (r *Recorder) GetAccount(hash util.Uint160, height uint32) (*state.Account, error) {
+	acc, err := r.Backend.GetAccount(hash, height)
+	r.record("GetAccount", []string{hash.StringBE(), formatIndex(height)}, encodeHex(acc, err), 0, err)
+	return acc, err
+}
+
+// GetAsset implements Backend interface.
+func (r *Recorder) GetAsset(id util.Uint256, height uint32) (*state.Asset, error) {
+	asset, err := r.Backend.GetAsset(id, height)
+	r.record("GetAsset", []string{id.StringBE(), formatIndex(height)}, encodeHex(asset, err), 0, err)
+	return asset, err
+}
+
+// GetValidators implements Backend interface.
+func (r *Recorder) GetValidators(height uint32) (keys.PublicKeys, error) {
+	pubs, err := r.Backend.GetValidators(height)
+	r.record("GetValidators", []string{formatIndex(height)}, encodeHex(&publicKeys{pubs}, err), 0, err)
+	return pubs, err
+}
+
+// NewReplayBackend loads a Cassette from the given file and returns a
+// ReplayBackend serving it.
+func NewReplayBackend(path string) (*ReplayBackend, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := new(Cassette)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return NewReplayBackendFromCassette(c)
+}
+
+// NewReplayBackendFromCassette returns a ReplayBackend serving the given
+// Cassette. If the same request was recorded several times the first
+// response is used.
+func NewReplayBackendFromCassette(c *Cassette) (*ReplayBackend, error) {
+	if c.Version != CassetteVersion {
+		return nil, fmt.Errorf("unsupported cassette version %d", c.Version)
+	}
+	r := &ReplayBackend{entries: make(map[string]CassetteEntry, len(c.Entries))}
+	for _, e := range c.Entries {
+		k := cassetteKey(e.Method, e.Params)
+		if _, ok := r.entries[k]; !ok {
+			r.entries[k] = e
+		}
+	}
+	return r, nil
+}
+
+// get returns a recorded response for the given request.
+func (r *ReplayBackend) get(method string, params ...string) (CassetteEntry, error) {
+	k := cassetteKey(method, params)
+	e, ok := r.entries[k]
+	if !ok {
+		return e, &Error{Method: method, Err: errors.New("request not recorded: " + k)}
+	}
+	if len(e.Error) == 0 {
+		return e, nil
+	}
+	// Keep ErrNotFound and Error recognizable for the callers.
+	switch {
+	case e.ErrorKind == cassetteBackend:
+		msg := strings.TrimPrefix(e.Error, e.ErrorMethod+": ")
+		return e, &Error{Method: e.ErrorMethod, Err: errors.New(msg)}
+	case e.ErrorKind == cassetteNotFound, strings.HasSuffix(e.Error, ErrNotFound.Error()):
+		return e, fmt.Errorf("%s%w", strings.TrimSuffix(e.Error, ErrNotFound.Error()), ErrNotFound)
+	default:
+		return e, errors.New(e.Error)
+	}
+}
+
+// HasHistory implements Backend interface. Requests at any height can be
+// recorded.
+func (r *ReplayBackend) HasHistory() bool {
+	return true
+}
+
+// GetHeight implements Backend interface.
+func (r *ReplayBackend) GetHeight() (uint32, error) {
+	e, err := r.get("GetHeight")
+	if err != nil {
+		return 0, err
+	}
+	height, err := strconv.ParseUint(e.Result, 10, 32)
+	return uint32(height), err
+}
+
+// GetBlockHash implements Backend interface.
+func (r *ReplayBackend) GetBlockHash(index uint32) (util.Uint256, error) {
+	e, err := r.get("GetBlockHash", formatIndex(index))
+	if err != nil {
+		return util.Uint256{}, err
+	}
+	return util.Uint256DecodeStringBE(e.Result)
+}
+
+// GetBlock implements Backend interface.
+func (r *ReplayBackend) GetBlock(hash util.Uint256) (*block.Block, error) {
+	e, err := r.get("GetBlock", hash.StringBE())
+	if err != nil {
+		return nil, err
+	}
+	blk := new(block.Block)
+	if err := decodeHex(e.Result, blk); err != nil {
+		return nil, err
+	}
+	return blk, nil
+}
+
+// GetHeader implements Backend interface.
+func (r *ReplayBackend) GetHeader(hash util.Uint256) (*block.Header, error) {
+	e, err := r.get("GetHeader", hash.StringBE())
+	if err != nil {
+		return nil, err
+	}
+	hd := new(block.Header)
+	if err := decodeHex(e.Result, hd); err != nil {
+		return nil, err
+	}
+	return hd, nil
+}
+
+// GetHeaderByIndex implements Backend interface.
+func (r *ReplayBackend) GetHeaderByIndex(index uint32) (*block.Header, error) {
+	e, err := r.get("GetHeaderByIndex", formatIndex(index))
+	if err != nil {
+		return nil, err
+	}
+	hd := new(block.Header)
+	if err := decodeHex(e.Result, hd); err != nil {
+		return nil, err
+	}
+	return hd, nil
+}
+
+// GetTransaction implements Backend interface.
+func (r *ReplayBackend) GetTransaction(hash util.Uint256) (*transaction.Transaction, uint32, error) {
+	e, err := r.get("GetTransaction", hash.StringBE())
+	if err != nil {
+		return nil, 0, err
+	}
+	tx := new(transaction.Transaction)
+	if err := decodeHex(e.Result, tx); err != nil {
+		return nil, 0, err
+	}
+	return tx, e.Height, nil
+}
+
+// GetContract implements Backend interface.
+func (r *ReplayBackend) GetContract(hash util.Uint160, height uint32) (*state.Contract, error) {
+	e, err := r.get("GetContract", hash.StringBE(), formatIndex(height))
+	if err != nil {
+		return nil, err
+	}
+	cs := new(state.Contract)
+	if err := decodeHex(e.Result, cs); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// GetStorage implements Backend interface.
+func (r *ReplayBackend) GetStorage(hash util.Uint160, key []byte, height uint32) (*state.StorageItem, error) {
+	e, err := r.get("GetStorage", hash.StringBE(), hex.EncodeToString(key), formatIndex(height))
+	if err != nil {
+		return nil, err
+	}
+	si := new(state.StorageItem)
+	if err := decodeHex(e.Result, si); err != nil {
+		return nil, err
+	}
+	return si, nil
+}
+
+// FindStorage implements Backend interface.
+func (r *ReplayBackend) FindStorage(hash util.Uint160, prefix []byte, height uint32) ([]KeyValue, error) {
+	e, err := r.get("FindStorage", hash.StringBE(), hex.EncodeToString(prefix), formatIndex(height))
+	if err != nil {
+		return nil, err
+	}
+	var kvs keyValues
+	if err := decodeHex(e.Result, &kvs); err != nil {
+		return nil, err
+	}
+	return kvs, nil
+}
+
+// GetAccount implements Backend interface.
+func (r *ReplayBackend) GetAccount(hash util.Uint160, height uint32) (*state.Account, error) {
+	e, err := r.get("GetAccount", hash.StringBE(), formatIndex(height))
+	if err != nil {
+		return nil, err
+	}
+	acc := new(state.Account)
+	if err := decodeHex(e.Result, acc); err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// GetAsset implements Backend interface.
+func (r *ReplayBackend) GetAsset(id util.Uint256, height uint32) (*state.Asset, error) {
+	e, err := r.get("GetAsset", id.StringBE(), formatIndex(height))
+	if err != nil {
+		return nil, err
+	}
+	asset := new(state.Asset)
+	if err := decodeHex(e.Result, asset); err != nil {
+		return nil, err
+	}
+	return asset, nil
+}
+
+// GetValidators implements Backend interface.
+func (r *ReplayBackend) GetValidators(height uint32) (keys.PublicKeys, error) {
+	e, err := r.get("GetValidators", formatIndex(height))
+	if err != nil {
+		return nil, err
+	}
+	pubs := new(publicKeys)
+	if err := decodeHex(e.Result, pubs); err != nil {
+		return nil, err
+	}
+	return pubs.keys, nil
+}
+
+// publicKeys is a serializable list of public keys.
+type publicKeys struct {
+	keys keys.PublicKeys
+}
+
+// EncodeBinary implements io.Serializable interface.
+func (p *publicKeys) EncodeBinary(w *io.BinWriter) {
+	w.WriteArray(p.keys)
+}
+
+// DecodeBinary implements io.Serializable interface.
+func (p *publicKeys) DecodeBinary(r *io.BinReader) {
+	r.ReadArray(&p.keys)
+}
+
+// keyValues is a serializable list of storage items.
+type keyValues []KeyValue
+
+// EncodeBinary implements io.Serializable interface.
+func (kvs *keyValues) EncodeBinary(w *io.BinWriter) {
+	w.WriteArray([]KeyValue(*kvs))
+}
+
+// DecodeBinary implements io.Serializable interface.
+func (kvs *keyValues) DecodeBinary(r *io.BinReader) {
+	r.ReadArray((*[]KeyValue)(kvs))
+}
+
+// cassetteKey returns a map key for the given request.
+func cassetteKey(method string, params []string) string {
+	return method + "(" + strings.Join(params, ",") + ")"
+}
+
+func formatIndex(index uint32) string {
+	return strconv.FormatUint(uint64(index), 10)
+}
+
+// encodeHex returns hex-encoded binary serialization of s unless err is set.
+func encodeHex(s io.Serializable, err error) string {
+	if err != nil {
+		return ""
+	}
+	w := io.NewBufBinWriter()
+	s.EncodeBinary(w.BinWriter)
+	if w.Err != nil {
+		return ""
+	}
+	return hex.EncodeToString(w.Bytes())
+}
@@ -0,0 +1,120 @@
+package chain
+
+import (
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/io"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// failingBackend is a FixtureBackend failing account and asset lookups.
+type failingBackend struct {
+	*FixtureBackend
+}
+
+func (b failingBackend) GetAccount(util.Uint160, uint32) (*state.Account, error) {
+	return nil, &Error{Method: "getaccountstate", Err: errors.New("connection refused")}
+}
+
+func (b failingBackend) GetAsset(util.Uint256, uint32) (*state.Asset, error) {
+	return nil, errors.New("asset is broken")
+}
+
+func TestCassetteRoundTrip(t *testing.T) {
+	contractHash := util.Uint160{1, 2, 3}
+	cs := &state.Contract{Script: []byte{0x51}, Name: "test"}
+	w := io.NewBufBinWriter()
+	cs.EncodeBinary(w.BinWriter)
+	fixture, err := NewFixtureBackendFromSnapshot(&Snapshot{
+		Version:   SnapshotVersion,
+		Height:    10,
+		Contracts: map[string]string{contractHash.StringBE(): hex.EncodeToString(w.Bytes())},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checks are performed on both the recorded and the replayed backend.
+	var checks = []struct {
+		name  string
+		check func(b Backend) error
+	}{
+		{"height", func(b Backend) error {
+			height, err := b.GetHeight()
+			if err == nil && height != 10 {
+				return errors.New("wrong height")
+			}
+			return err
+		}},
+		{"contract", func(b Backend) error {
+			c, err := b.GetContract(contractHash, 10)
+			if err == nil && (c.Name != cs.Name || string(c.Script) != string(cs.Script)) {
+				return errors.New("wrong contract")
+			}
+			return err
+		}},
+		{"not found", func(b Backend) error {
+			_, err := b.GetContract(util.Uint160{4}, 10)
+			if !errors.Is(err, ErrNotFound) {
+				return errors.New("ErrNotFound expected")
+			}
+			return nil
+		}},
+		{"backend error", func(b Backend) error {
+			_, err := b.GetAccount(util.Uint160{5}, 10)
+			var berr *Error
+			if !errors.As(err, &berr) || berr.Method != "getaccountstate" ||
+				err.Error() != "getaccountstate: connection refused" {
+				return errors.New("backend error expected")
+			}
+			return nil
+		}},
+		{"other error", func(b Backend) error {
+			_, err := b.GetAsset(util.Uint256{6}, 10)
+			var berr *Error
+			if err == nil || errors.As(err, &berr) || errors.Is(err, ErrNotFound) || err.Error() != "asset is broken" {
+				return errors.New("plain error expected")
+			}
+			return nil
+		}},
+	}
+
+	r := NewRecorder(failingBackend{fixture})
+	for _, c := range checks {
+		if err := c.check(r); err != nil {
+			t.Fatalf("recording %s: %v", c.name, err)
+		}
+	}
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cassette.json")
+	if err := r.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := NewReplayBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range checks {
+		if err := c.check(replay); err != nil {
+			t.Errorf("replaying %s: %v", c.name, err)
+		}
+	}
+
+	// Requests not recorded are backend failures.
+	_, err = replay.GetContract(contractHash, 9)
+	var berr *Error
+	if !errors.As(err, &berr) || berr.Method != "GetContract" || errors.Is(err, ErrNotFound) {
+		t.Errorf("expected backend error for a request not recorded, got %v", err)
+	}
+}
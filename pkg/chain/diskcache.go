@@ -0,0 +1,235 @@
+package chain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/io"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// DiskCache is a Backend wrapper keeping immutable entities (block hashes,
+// blocks, headers and contracts at a given height) on disk, so that they're
+// fetched once for all runs sharing the directory. Every entity is stored in
+// its own file named after its hash or height, in its binary serialization
+// format. Files are written atomically, so the directory can be shared by
+// multiple processes. Once the size limit is exceeded least recently used
+// files are removed.
+//
+// The cache is best-effort, failures to read or write it result in backend
+// requests.
+type DiskCache struct {
+	Backend
+
+	dir   string
+	limit int64
+
+	lock sync.Mutex
+	// size is the estimated size of the directory, other processes can
+	// change it.
+	size int64
+}
+
+// tempPrefix is the prefix of files being written to the cache.
+const tempPrefix = ".tmp-"
+
+// NewDiskCache returns a new DiskCache wrapping the given Backend and storing
+// at most limit bytes in the dir, which is created if needed.
+func NewDiskCache(b Backend, dir string, limit int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	c := &DiskCache{
+		Backend: b,
+		dir:     dir,
+		limit:   limit,
+	}
+	files, err := c.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range files {
+		c.size += fi.Size()
+	}
+	return c, nil
+}
+
+// GetBlockHash implements Backend interface.
+func (c *DiskCache) GetBlockHash(index uint32) (util.Uint256, error) {
+	var hash util.Uint256
+	k := "blockhash-" + formatIndex(index)
+	if c.get(k, &hash) {
+		return hash, nil
+	}
+	hash, err := c.Backend.GetBlockHash(index)
+	if err != nil {
+		return hash, err
+	}
+	c.put(k, &hash)
+	return hash, nil
+}
+
+// GetBlock implements Backend interface.
+func (c *DiskCache) GetBlock(hash util.Uint256) (*block.Block, error) {
+	b := new(block.Block)
+	k := "block-" + hash.StringBE()
+	if c.get(k, b) {
+		return b, nil
+	}
+	b, err := c.Backend.GetBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+	c.put(k, b)
+	return b, nil
+}
+
+// GetHeader implements Backend interface.
+func (c *DiskCache) GetHeader(hash util.Uint256) (*block.Header, error) {
+	h := new(block.Header)
+	k := "header-" + hash.StringBE()
+	if c.get(k, h) {
+		return h, nil
+	}
+	h, err := c.Backend.GetHeader(hash)
+	if err != nil {
+		return nil, err
+	}
+	c.put(k, h)
+	return h, nil
+}
+
+// GetHeaderByIndex implements Backend interface.
+func (c *DiskCache) GetHeaderByIndex(index uint32) (*block.Header, error) {
+	h := new(block.Header)
+	k := "headerbyindex-" + formatIndex(index)
+	if c.get(k, h) {
+		return h, nil
+	}
+	h, err := c.Backend.GetHeaderByIndex(index)
+	if err != nil {
+		return nil, err
+	}
+	c.put(k, h)
+	return h, nil
+}
+
+// GetContract implements Backend interface.
+func (c *DiskCache) GetContract(hash util.Uint160, height uint32) (*state.Contract, error) {
+	cs := new(state.Contract)
+	k := "contract-" + hash.StringBE() + "-" + formatIndex(height)
+	if c.get(k, cs) {
+		return cs, nil
+	}
+	cs, err := c.Backend.GetContract(hash, height)
+	if err != nil {
+		return nil, err
+	}
+	c.put(k, cs)
+	return cs, nil
+}
+
+// get decodes the file into s and marks it as recently used. Broken files
+// are removed.
+func (c *DiskCache) get(k string, s io.Serializable) bool {
+	path := filepath.Join(c.dir, k)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	if err := decodeBinary(data, s); err != nil {
+		os.Remove(path)
+		return false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return true
+}
+
+// put writes s into the file and evicts old files if the cache is full.
+func (c *DiskCache) put(k string, s io.Serializable) {
+	w := io.NewBufBinWriter()
+	s.EncodeBinary(w.BinWriter)
+	if w.Err != nil {
+		return
+	}
+	data := w.Bytes()
+	f, err := ioutil.TempFile(c.dir, tempPrefix)
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// The file may be there already if it's been put by another process
+	// or a concurrent request.
+	path := filepath.Join(c.dir, k)
+	var old int64
+	if fi, err := os.Stat(path); err == nil {
+		old = fi.Size()
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return
+	}
+	c.size += int64(len(data)) - old
+	if c.size > c.limit {
+		c.evict()
+	}
+}
+
+// evict removes least recently used files until the cache fits the limit.
+// The directory is rescanned, so that files of other processes are taken
+// into account.
+func (c *DiskCache) evict() {
+	files, err := c.files()
+	if err != nil {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	c.size = 0
+	for _, fi := range files {
+		c.size += fi.Size()
+	}
+	for _, fi := range files {
+		if c.size <= c.limit {
+			break
+		}
+		err := os.Remove(filepath.Join(c.dir, fi.Name()))
+		if err == nil || os.IsNotExist(err) {
+			c.size -= fi.Size()
+		}
+	}
+}
+
+// files returns the cached files.
+func (c *DiskCache) files() ([]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+	files := infos[:0]
+	for _, fi := range infos {
+		if fi.Mode().IsRegular() && !strings.HasPrefix(fi.Name(), tempPrefix) {
+			files = append(files, fi)
+		}
+	}
+	return files, nil
+}
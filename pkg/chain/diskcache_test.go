@@ -0,0 +1,145 @@
+package chain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// hashBackend is a Backend serving block hashes derived from indexes and
+// counting requests for them.
+type hashBackend struct {
+	Backend
+	calls int32
+}
+
+func (b *hashBackend) GetBlockHash(index uint32) (util.Uint256, error) {
+	atomic.AddInt32(&b.calls, 1)
+	return indexHash(index), nil
+}
+
+func indexHash(index uint32) util.Uint256 {
+	return util.Uint256{byte(index), byte(index >> 8), 0xff}
+}
+
+func newTestDiskCache(t *testing.T, b Backend, dir string, limit int64) *DiskCache {
+	c, err := NewDiskCache(b, dir, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestDiskCacheEviction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Three hashes fit.
+	b := new(hashBackend)
+	c := newTestDiskCache(t, b, dir, 3*util.Uint256Size)
+	for i := uint32(1); i <= 3; i++ {
+		if _, err := c.GetBlockHash(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 2 is the least recently used one.
+	base := time.Now().Add(-time.Hour)
+	for i, age := range map[uint32]time.Duration{1: 3, 2: 1, 3: 2} {
+		mtime := base.Add(age * time.Minute)
+		if err := os.Chtimes(filepath.Join(dir, "blockhash-"+formatIndex(i)), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Getting 1 makes it the most recently used one, so 3 goes next.
+	if _, err := c.GetBlockHash(1); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []uint32{4, 5} {
+		if _, err := c.GetBlockHash(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, cached := range map[uint32]bool{1: true, 2: false, 3: false, 4: true, 5: true} {
+		_, err := os.Stat(filepath.Join(dir, "blockhash-"+formatIndex(i)))
+		if cached != (err == nil) {
+			t.Errorf("%d: expected to be cached %t, got %v", i, cached, err)
+		}
+	}
+	if calls := atomic.LoadInt32(&b.calls); calls != 5 {
+		t.Errorf("expected 5 backend requests, got %d", calls)
+	}
+
+	// Overwriting a file doesn't change the size.
+	c = newTestDiskCache(t, b, dir, 10*util.Uint256Size)
+	hash := indexHash(1)
+	c.put("blockhash-"+formatIndex(1), &hash)
+	if c.size != 3*util.Uint256Size {
+		t.Errorf("expected size %d, got %d", 3*util.Uint256Size, c.size)
+	}
+}
+
+func TestDiskCacheShared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Two processes sharing the directory, all hashes fit.
+	const n = 50
+	b := new(hashBackend)
+	caches := []*DiskCache{
+		newTestDiskCache(t, b, dir, 10*n*util.Uint256Size),
+		newTestDiskCache(t, b, dir, 10*n*util.Uint256Size),
+	}
+	var wg sync.WaitGroup
+	for _, c := range caches {
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func(c *DiskCache) {
+				defer wg.Done()
+				for i := uint32(0); i < n; i++ {
+					hash, err := c.GetBlockHash(i)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if hash != indexHash(i) {
+						t.Errorf("%d: expected %s, got %s", i, indexHash(i).StringBE(), hash.StringBE())
+					}
+				}
+			}(c)
+		}
+	}
+	wg.Wait()
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != n {
+		t.Errorf("expected %d files, got %d", n, len(infos))
+	}
+	for _, fi := range infos {
+		if strings.HasPrefix(fi.Name(), tempPrefix) {
+			t.Errorf("temporary file %s is left", fi.Name())
+		}
+	}
+	// Files put by the other process aren't known, but files put several
+	// times aren't counted twice.
+	for i, c := range caches {
+		if c.size > n*util.Uint256Size {
+			t.Errorf("cache %d: expected size at most %d, got %d", i, n*util.Uint256Size, c.size)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package chain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neo-go/pkg/io"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// SnapshotVersion is the version of snapshot file format understood by
+// FixtureBackend.
+const SnapshotVersion = 1
+
+// Snapshot is a JSON representation of chain state served by FixtureBackend.
+// All entities are stored as hex-encoded binary serializations of their
+// respective structures.
+type Snapshot struct {
+	Version int    `json:"version"`
+	Height  uint32 `json:"height"`
+	// Contracts maps BE contract hash to state.Contract.
+	Contracts map[string]string `json:"contracts"`
+	// Storage maps BE contract hash to a map of hex-encoded keys to
+	// state.StorageItem.
+	Storage map[string]map[string]string `json:"storage"`
+	// Accounts maps BE account script hash to state.Account.
+	Accounts map[string]string `json:"accounts"`
+	// Assets maps BE asset ID to state.Asset.
+	Assets map[string]string `json:"assets"`
+	// StandbyValidators is a list of hex-encoded standby validator keys.
+	StandbyValidators []string `json:"standby_validators"`
+	// Validators is a list of state.Validator.
+	Validators []string `json:"validators"`
+	// ValidatorsCount is state.ValidatorsCount.
+	ValidatorsCount string `json:"validators_count"`
+	// Blocks is a list of block.Block.
+	Blocks []string `json:"blocks"`
+	// Headers is a list of block.Header for blocks that are not present in
+	// Blocks.
+	Headers []string `json:"headers"`
+	// Transactions is a list of transactions not included into Blocks.
+	Transactions []SnapshotTx `json:"transactions"`
+}
+
+// SnapshotTx is a transaction.Transaction along with its height.
+type SnapshotTx struct {
+	Tx     string `json:"tx"`
+	Height uint32 `json:"height"`
+}
+
+// FixtureBackend is a Backend serving chain state from a Snapshot. It doesn't
+// keep any history, so heights passed to it are ignored. Contracts, accounts
+// and assets are returned as copies, so changing them doesn't change the
+// snapshot.
+type FixtureBackend struct {
+	height     uint32
+	contracts  map[util.Uint160]*state.Contract
+	storage    map[string]*state.StorageItem
+	accounts   map[util.Uint160]*state.Account
+	assets     map[util.Uint256]*state.Asset
+	validators keys.PublicKeys
+	headers    map[util.Uint256]*block.Header
+	blocks     map[util.Uint256]*block.Block
+	hashes     map[uint32]util.Uint256
+	txes       map[util.Uint256]fixtureTx
+}
+
+type fixtureTx struct {
+	tx     *transaction.Transaction
+	height uint32
+}
+
+// NewFixtureBackend loads a Snapshot from the given file and returns a
+// FixtureBackend serving it.
+func NewFixtureBackend(path string) (*FixtureBackend, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := new(Snapshot)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return NewFixtureBackendFromSnapshot(s)
+}
+
+// NewFixtureBackendFromSnapshot returns a FixtureBackend serving the given
+// Snapshot.
+func NewFixtureBackendFromSnapshot(s *Snapshot) (*FixtureBackend, error) {
+	if s.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", s.Version)
+	}
+	f := &FixtureBackend{
+		height:    s.Height,
+		contracts: make(map[util.Uint160]*state.Contract),
+		storage:   make(map[string]*state.StorageItem),
+		accounts:  make(map[util.Uint160]*state.Account),
+		assets:    make(map[util.Uint256]*state.Asset),
+		headers:   make(map[util.Uint256]*block.Header),
+		blocks:    make(map[util.Uint256]*block.Block),
+		hashes:    make(map[uint32]util.Uint256),
+		txes:      make(map[util.Uint256]fixtureTx),
+	}
+	for h, c := range s.Contracts {
+		hash, err := util.Uint160DecodeStringBE(h)
+		if err != nil {
+			return nil, err
+		}
+		cs := new(state.Contract)
+		if err := decodeHex(c, cs); err != nil {
+			return nil, fmt.Errorf("contract %s: %v", h, err)
+		}
+		f.contracts[hash] = cs
+	}
+	for h, items := range s.Storage {
+		hash, err := util.Uint160DecodeStringBE(h)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range items {
+			key, err := hex.DecodeString(k)
+			if err != nil {
+				return nil, err
+			}
+			si := new(state.StorageItem)
+			if err := decodeHex(v, si); err != nil {
+				return nil, fmt.Errorf("storage item %s/%s: %v", h, k, err)
+			}
+			f.storage[storageKey(hash, key)] = si
+		}
+	}
+	for h, a := range s.Accounts {
+		hash, err := util.Uint160DecodeStringBE(h)
+		if err != nil {
+			return nil, err
+		}
+		acc := new(state.Account)
+		if err := decodeHex(a, acc); err != nil {
+			return nil, fmt.Errorf("account %s: %v", h, err)
+		}
+		f.accounts[hash] = acc
+	}
+	for h, a := range s.Assets {
+		id, err := util.Uint256DecodeStringBE(h)
+		if err != nil {
+			return nil, err
+		}
+		asset := new(state.Asset)
+		if err := decodeHex(a, asset); err != nil {
+			return nil, fmt.Errorf("asset %s: %v", h, err)
+		}
+		f.assets[id] = asset
+	}
+	standby := make(keys.PublicKeys, 0, len(s.StandbyValidators))
+	for _, str := range s.StandbyValidators {
+		pub, err := keys.NewPublicKeyFromString(str)
+		if err != nil {
+			return nil, err
+		}
+		standby = append(standby, pub)
+	}
+	validators := make([]*state.Validator, 0, len(s.Validators))
+	for i, str := range s.Validators {
+		v := new(state.Validator)
+		if err := decodeHex(str, v); err != nil {
+			return nil, fmt.Errorf("validator #%d: %v", i, err)
+		}
+		validators = append(validators, v)
+	}
+	count := new(state.ValidatorsCount)
+	if len(s.ValidatorsCount) != 0 {
+		if err := decodeHex(s.ValidatorsCount, count); err != nil {
+			return nil, fmt.Errorf("validators count: %v", err)
+		}
+	}
+	f.validators = ComputeValidators(standby, validators, count)
+	for i, b := range s.Blocks {
+		blk := new(block.Block)
+		if err := decodeHex(b, blk); err != nil {
+			return nil, fmt.Errorf("block #%d: %v", i, err)
+		}
+		f.blocks[blk.Hash()] = blk
+		f.addHeader(blk.Header())
+		for _, tx := range blk.Transactions {
+			f.txes[tx.Hash()] = fixtureTx{tx: tx, height: blk.Index}
+		}
+	}
+	for i, h := range s.Headers {
+		hd := new(block.Header)
+		if err := decodeHex(h, hd); err != nil {
+			return nil, fmt.Errorf("header #%d: %v", i, err)
+		}
+		f.addHeader(hd)
+	}
+	for i, t := range s.Transactions {
+		tx := new(transaction.Transaction)
+		if err := decodeHex(t.Tx, tx); err != nil {
+			return nil, fmt.Errorf("transaction #%d: %v", i, err)
+		}
+		f.txes[tx.Hash()] = fixtureTx{tx: tx, height: t.Height}
+	}
+	return f, nil
+}
+
+func (f *FixtureBackend) addHeader(hd *block.Header) {
+	f.headers[hd.Hash()] = hd
+	f.hashes[hd.Index] = hd.Hash()
+}
+
+// HasHistory implements Backend interface.
+func (f *FixtureBackend) HasHistory() bool {
+	return false
+}
+
+// GetHeight implements Backend interface.
+func (f *FixtureBackend) GetHeight() (uint32, error) {
+	return f.height, nil
+}
+
+// GetBlockHash implements Backend interface.
+func (f *FixtureBackend) GetBlockHash(index uint32) (util.Uint256, error) {
+	hash, ok := f.hashes[index]
+	if !ok {
+		return hash, fmt.Errorf("block #%d %w", index, ErrNotFound)
+	}
+	return hash, nil
+}
+
+// GetBlock implements Backend interface.
+func (f *FixtureBackend) GetBlock(hash util.Uint256) (*block.Block, error) {
+	blk, ok := f.blocks[hash]
+	if !ok {
+		return nil, fmt.Errorf("block %s %w", hash.StringLE(), ErrNotFound)
+	}
+	return blk, nil
+}
+
+// GetHeader implements Backend interface.
+func (f *FixtureBackend) GetHeader(hash util.Uint256) (*block.Header, error) {
+	hd, ok := f.headers[hash]
+	if !ok {
+		return nil, fmt.Errorf("header %s %w", hash.StringLE(), ErrNotFound)
+	}
+	return hd, nil
+}
+
+// GetHeaderByIndex implements Backend interface.
+func (f *FixtureBackend) GetHeaderByIndex(index uint32) (*block.Header, error) {
+	hash, err := f.GetBlockHash(index)
+	if err != nil {
+		return nil, err
+	}
+	return f.GetHeader(hash)
+}
+
+// GetTransaction implements Backend interface.
+func (f *FixtureBackend) GetTransaction(hash util.Uint256) (*transaction.Transaction, uint32, error) {
+	t, ok := f.txes[hash]
+	if !ok {
+		return nil, 0, fmt.Errorf("transaction %s %w", hash.StringLE(), ErrNotFound)
+	}
+	return t.tx, t.height, nil
+}
+
+// GetContract implements Backend interface.
+func (f *FixtureBackend) GetContract(hash util.Uint160, _ uint32) (*state.Contract, error) {
+	cs, ok := f.contracts[hash]
+	if !ok {
+		return nil, fmt.Errorf("contract %s %w", hash.StringBE(), ErrNotFound)
+	}
+	res := new(state.Contract)
+	if err := clone(cs, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetStorage implements Backend interface.
+func (f *FixtureBackend) GetStorage(hash util.Uint160, key []byte, _ uint32) (*state.StorageItem, error) {
+	si, ok := f.storage[storageKey(hash, key)]
+	if !ok {
+		return nil, fmt.Errorf("storage item %s/%x %w", hash.StringBE(), key, ErrNotFound)
+	}
+	return si, nil
+}
+
+// FindStorage implements Backend interface.
+func (f *FixtureBackend) FindStorage(hash util.Uint160, prefix []byte, _ uint32) ([]KeyValue, error) {
+	sp := storageKey(hash, prefix)
+	kvs := []KeyValue{}
+	for k, si := range f.storage {
+		if strings.HasPrefix(k, sp) {
+			kvs = append(kvs, KeyValue{Key: []byte(k[util.Uint160Size:]), Value: si.Value})
+		}
+	}
+	return kvs, nil
+}
+
+// GetAccount implements Backend interface.
+func (f *FixtureBackend) GetAccount(hash util.Uint160, _ uint32) (*state.Account, error) {
+	acc, ok := f.accounts[hash]
+	if !ok {
+		return nil, fmt.Errorf("account %s %w", hash.StringBE(), ErrNotFound)
+	}
+	res := new(state.Account)
+	if err := clone(acc, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetAsset implements Backend interface.
+func (f *FixtureBackend) GetAsset(id util.Uint256, _ uint32) (*state.Asset, error) {
+	asset, ok := f.assets[id]
+	if !ok {
+		return nil, fmt.Errorf("asset %s %w", id.StringLE(), ErrNotFound)
+	}
+	res := new(state.Asset)
+	if err := clone(asset, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetValidators implements Backend interface.
+func (f *FixtureBackend) GetValidators(_ uint32) (keys.PublicKeys, error) {
+	return f.validators, nil
+}
+
+// clone copies src into dst via their binary serialization.
+func clone(src, dst io.Serializable) error {
+	w := io.NewBufBinWriter()
+	src.EncodeBinary(w.BinWriter)
+	if w.Err != nil {
+		return w.Err
+	}
+	return decodeBinary(w.Bytes(), dst)
+}
+
+// storageKey returns a map key for the given contract storage item.
+func storageKey(hash util.Uint160, key []byte) string {
+	return string(hash[:]) + string(key)
+}
@@ -0,0 +1,136 @@
+package chain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neo-go/pkg/io"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// encodeSnapshotHex returns hex-encoded binary serialization of s.
+func encodeSnapshotHex(t *testing.T, s io.Serializable) string {
+	w := io.NewBufBinWriter()
+	s.EncodeBinary(w.BinWriter)
+	if w.Err != nil {
+		t.Fatal(w.Err)
+	}
+	return hex.EncodeToString(w.Bytes())
+}
+
+func TestNewFixtureBackendFromSnapshotVersion(t *testing.T) {
+	for _, version := range []int{0, SnapshotVersion + 1} {
+		if _, err := NewFixtureBackendFromSnapshot(&Snapshot{Version: version}); err == nil {
+			t.Errorf("version %d is accepted", version)
+		}
+	}
+	if _, err := NewFixtureBackendFromSnapshot(&Snapshot{Version: SnapshotVersion}); err != nil {
+		t.Errorf("version %d: %v", SnapshotVersion, err)
+	}
+}
+
+func TestFixtureBackendFindStorage(t *testing.T) {
+	hash, other := util.Uint160{1}, util.Uint160{2}
+	item := func(v string) string {
+		return encodeSnapshotHex(t, &state.StorageItem{Value: []byte(v)})
+	}
+	f, err := NewFixtureBackendFromSnapshot(&Snapshot{
+		Version: SnapshotVersion,
+		Storage: map[string]map[string]string{
+			hash.StringBE(): {
+				hex.EncodeToString([]byte("a1")): item("1"),
+				hex.EncodeToString([]byte("a2")): item("2"),
+				hex.EncodeToString([]byte("b1")): item("3"),
+				hex.EncodeToString([]byte("")):   item("4"),
+			},
+			other.StringBE(): {
+				hex.EncodeToString([]byte("a3")): item("5"),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		prefix   string
+		expected map[string]string
+	}{
+		{"a", map[string]string{"a1": "1", "a2": "2"}},
+		{"a1", map[string]string{"a1": "1"}},
+		{"c", map[string]string{}},
+		{"", map[string]string{"a1": "1", "a2": "2", "b1": "3", "": "4"}},
+	}
+	for _, tc := range testCases {
+		kvs, err := f.FindStorage(hash, []byte(tc.prefix), 0)
+		if err != nil {
+			t.Fatalf("prefix %q: %v", tc.prefix, err)
+		}
+		if len(kvs) != len(tc.expected) {
+			t.Errorf("prefix %q: expected %d items, got %d", tc.prefix, len(tc.expected), len(kvs))
+		}
+		for _, kv := range kvs {
+			if v, ok := tc.expected[string(kv.Key)]; !ok || v != string(kv.Value) {
+				t.Errorf("prefix %q: unexpected item %s=%s", tc.prefix, kv.Key, kv.Value)
+			}
+		}
+	}
+}
+
+func TestFixtureBackendCopies(t *testing.T) {
+	priv, err := keys.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, id := util.Uint160{1}, util.Uint256{2}
+	acc := state.NewAccount(hash)
+	acc.Votes = []*keys.PublicKey{priv.PublicKey()}
+	acc.Balances[id] = []state.UnspentBalance{{Value: 10}}
+	f, err := NewFixtureBackendFromSnapshot(&Snapshot{
+		Version:   SnapshotVersion,
+		Contracts: map[string]string{hash.StringBE(): encodeSnapshotHex(t, &state.Contract{Script: []byte{0x51}, Name: "test"})},
+		Accounts:  map[string]string{hash.StringBE(): encodeSnapshotHex(t, acc)},
+		Assets: map[string]string{id.StringBE(): encodeSnapshotHex(t, &state.Asset{
+			ID:        id,
+			AssetType: transaction.Token,
+			Name:      "test",
+			Owner:     *priv.PublicKey(),
+		})},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := f.GetContract(hash, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.Name = "changed"
+	cs.Script[0] = 0x52
+	if cs, _ = f.GetContract(hash, 0); cs.Name != "test" || cs.Script[0] != 0x51 {
+		t.Errorf("contract is changed: %s %x", cs.Name, cs.Script)
+	}
+
+	a, err := f.GetAccount(hash, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.IsFrozen = true
+	a.Votes[0] = nil
+	a.Balances[id][0].Value = 20
+	if a, _ = f.GetAccount(hash, 0); a.IsFrozen || a.Votes[0] == nil || a.Balances[id][0].Value != 10 {
+		t.Errorf("account is changed: %+v", a)
+	}
+
+	asset, err := f.GetAsset(id, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset.Name = "changed"
+	if asset, _ = f.GetAsset(id, 0); asset.Name != "test" {
+		t.Errorf("asset is changed: %s", asset.Name)
+	}
+}
@@ -0,0 +1,332 @@
+package chain
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neo-go/pkg/encoding/address"
+	"github.com/nspcc-dev/neo-go/pkg/io"
+	"github.com/nspcc-dev/neo-go/pkg/rpc"
+	"github.com/nspcc-dev/neo-go/pkg/smartcontract"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// NeoCLIBackend is a Backend talking to a stock NEO 2.x node via the standard
+// JSON-RPC methods. They only return the current chain state, so contract,
+// storage, account, asset and validators lookups ignore the height given.
+// Storage search isn't supported by them.
+type NeoCLIBackend struct {
+	client *rpc.Client
+}
+
+// errUnknown is the code of neo-cli errors for missing entities.
+const errUnknown = -100
+
+// contractState is a getcontractstate result.
+type contractState struct {
+	Script      string                    `json:"script"`
+	Parameters  []smartcontract.ParamType `json:"parameters"`
+	ReturnType  smartcontract.ParamType   `json:"returntype"`
+	Name        string                    `json:"name"`
+	CodeVersion string                    `json:"code_version"`
+	Author      string                    `json:"author"`
+	Email       string                    `json:"email"`
+	Description string                    `json:"description"`
+	Properties  struct {
+		Storage       bool `json:"storage"`
+		DynamicInvoke bool `json:"dynamic_invoke"`
+		Payable       bool `json:"payable"`
+	} `json:"properties"`
+}
+
+// accountState is a getaccountstate result.
+type accountState struct {
+	Version    uint8             `json:"version"`
+	ScriptHash util.Uint160      `json:"script_hash"`
+	Frozen     bool              `json:"frozen"`
+	Votes      []*keys.PublicKey `json:"votes"`
+	Balances   []struct {
+		Asset util.Uint256 `json:"asset"`
+		Value util.Fixed8  `json:"value"`
+	} `json:"balances"`
+}
+
+// assetState is a getassetstate result.
+type assetState struct {
+	ID         util.Uint256    `json:"id"`
+	Type       string          `json:"type"`
+	Name       json.RawMessage `json:"name"`
+	Amount     util.Fixed8     `json:"amount"`
+	Available  util.Fixed8     `json:"available"`
+	Precision  uint8           `json:"precision"`
+	Owner      keys.PublicKey  `json:"owner"`
+	Admin      string          `json:"admin"`
+	Issuer     string          `json:"issuer"`
+	Expiration uint32          `json:"expiration"`
+	Frozen     bool            `json:"frozen"`
+}
+
+// validatorState is a getvalidators result item.
+type validatorState struct {
+	PublicKey string `json:"publickey"`
+	Active    bool   `json:"active"`
+}
+
+// assetTypes maps C# asset type names to asset types.
+var assetTypes = map[string]transaction.AssetType{
+	"GoverningToken": transaction.GoverningToken,
+	"UtilityToken":   transaction.UtilityToken,
+	"Currency":       transaction.Currency,
+	"Share":          transaction.Share,
+	"Invoice":        transaction.Invoice,
+	"Token":          transaction.Token,
+}
+
+// NewNeoCLIBackend returns a new NeoCLIBackend using the given client.
+func NewNeoCLIBackend(c *rpc.Client) *NeoCLIBackend {
+	return &NeoCLIBackend{client: c}
+}
+
+// HasHistory implements Backend interface.
+func (n *NeoCLIBackend) HasHistory() bool {
+	return false
+}
+
+// GetHeight implements Backend interface.
+func (n *NeoCLIBackend) GetHeight() (uint32, error) {
+	var count uint32
+	if err := n.client.Call(context.Background(), "getblockcount", []interface{}{}, &count); err != nil {
+		return 0, &Error{Method: "getblockcount", Err: err}
+	}
+	return count - 1, nil
+}
+
+// GetBlockHash implements Backend interface.
+func (n *NeoCLIBackend) GetBlockHash(index uint32) (util.Uint256, error) {
+	var hash util.Uint256
+	if err := n.client.Call(context.Background(), "getblockhash", []interface{}{index}, &hash); err != nil {
+		return hash, n.wrap("getblockhash", fmt.Sprintf("block %d", index), err)
+	}
+	return hash, nil
+}
+
+// GetBlock implements Backend interface.
+func (n *NeoCLIBackend) GetBlock(hash util.Uint256) (*block.Block, error) {
+	blk := new(block.Block)
+	if err := n.callBinary("getblock", []interface{}{"0x" + hash.StringLE(), 0}, blk); err != nil {
+		return nil, n.wrap("getblock", "block "+hash.StringLE(), err)
+	}
+	return blk, nil
+}
+
+// GetHeader implements Backend interface.
+func (n *NeoCLIBackend) GetHeader(hash util.Uint256) (*block.Header, error) {
+	return n.getHeader("0x"+hash.StringLE(), "header "+hash.StringLE())
+}
+
+// GetHeaderByIndex implements Backend interface.
+func (n *NeoCLIBackend) GetHeaderByIndex(index uint32) (*block.Header, error) {
+	return n.getHeader(index, fmt.Sprintf("header %d", index))
+}
+
+func (n *NeoCLIBackend) getHeader(param interface{}, what string) (*block.Header, error) {
+	hd := new(block.Header)
+	if err := n.callBinary("getblockheader", []interface{}{param, 0}, hd); err != nil {
+		return nil, n.wrap("getblockheader", what, err)
+	}
+	return hd, nil
+}
+
+// GetTransaction implements Backend interface. The height is computed from
+// the number of confirmations, it's UnknownHeight for unconfirmed ones.
+func (n *NeoCLIBackend) GetTransaction(hash util.Uint256) (*transaction.Transaction, uint32, error) {
+	var (
+		raw   string
+		info  struct{ Confirmations uint32 }
+		count uint32
+		param = "0x" + hash.StringLE()
+	)
+	reqs := []*rpc.Request{
+		{Method: "getrawtransaction", Params: []interface{}{param, 0}, Result: &raw},
+		{Method: "getrawtransaction", Params: []interface{}{param, 1}, Result: &info},
+		{Method: "getblockcount", Params: []interface{}{}, Result: &count},
+	}
+	err := n.client.Batch(context.Background(), reqs)
+	for i := 0; err == nil && i < len(reqs); i++ {
+		err = reqs[i].Err
+	}
+	if err != nil {
+		return nil, 0, n.wrap("getrawtransaction", "transaction "+hash.StringLE(), err)
+	}
+	tx := new(transaction.Transaction)
+	if err := decodeHex(raw, tx); err != nil {
+		return nil, 0, &Error{Method: "getrawtransaction", Err: err}
+	}
+	// Unconfirmed (mempool) transactions have no height.
+	if info.Confirmations == 0 {
+		return tx, UnknownHeight, nil
+	}
+	return tx, count - info.Confirmations, nil
+}
+
+// GetContract implements Backend interface.
+func (n *NeoCLIBackend) GetContract(hash util.Uint160, _ uint32) (*state.Contract, error) {
+	var cs contractState
+	if err := n.client.Call(context.Background(), "getcontractstate", []interface{}{"0x" + hash.StringLE()}, &cs); err != nil {
+		return nil, n.wrap("getcontractstate", "contract "+hash.StringBE(), err)
+	}
+	script, err := hex.DecodeString(cs.Script)
+	if err != nil {
+		return nil, &Error{Method: "getcontractstate", Err: err}
+	}
+	var props smartcontract.PropertyState
+	if cs.Properties.Storage {
+		props |= smartcontract.HasStorage
+	}
+	if cs.Properties.DynamicInvoke {
+		props |= smartcontract.HasDynamicInvoke
+	}
+	if cs.Properties.Payable {
+		props |= smartcontract.IsPayable
+	}
+	return &state.Contract{
+		Script:      script,
+		ParamList:   cs.Parameters,
+		ReturnType:  cs.ReturnType,
+		Properties:  props,
+		Name:        cs.Name,
+		CodeVersion: cs.CodeVersion,
+		Author:      cs.Author,
+		Email:       cs.Email,
+		Description: cs.Description,
+	}, nil
+}
+
+// GetStorage implements Backend interface. The node returns a bare value
+// without the item flags, so items are never reported as constant.
+func (n *NeoCLIBackend) GetStorage(hash util.Uint160, key []byte, _ uint32) (*state.StorageItem, error) {
+	var str *string
+	err := n.client.Call(context.Background(), "getstorage", []interface{}{"0x" + hash.StringLE(), hex.EncodeToString(key)}, &str)
+	if err != nil {
+		return nil, n.wrap("getstorage", fmt.Sprintf("storage item %s/%x", hash.StringBE(), key), err)
+	}
+	if str == nil || len(*str) == 0 {
+		return nil, fmt.Errorf("storage item %s/%x %w", hash.StringBE(), key, ErrNotFound)
+	}
+	val, err := hex.DecodeString(*str)
+	if err != nil {
+		return nil, &Error{Method: "getstorage", Err: err}
+	}
+	return &state.StorageItem{Value: val}, nil
+}
+
+// FindStorage implements Backend interface. There is no standard method for
+// it, so it always fails.
+func (n *NeoCLIBackend) FindStorage(_ util.Uint160, _ []byte, _ uint32) ([]KeyValue, error) {
+	return nil, &Error{Method: "findstorage", Err: errors.New("not supported by neo-cli")}
+}
+
+// GetAccount implements Backend interface. The node returns an empty state
+// for unknown accounts, so they're never reported as missing.
+func (n *NeoCLIBackend) GetAccount(hash util.Uint160, _ uint32) (*state.Account, error) {
+	var as accountState
+	if err := n.client.Call(context.Background(), "getaccountstate", []interface{}{address.Uint160ToString(hash)}, &as); err != nil {
+		return nil, n.wrap("getaccountstate", "account "+hash.StringBE(), err)
+	}
+	acc := state.NewAccount(hash)
+	acc.Version = as.Version
+	acc.IsFrozen = as.Frozen
+	acc.Votes = as.Votes
+	for _, b := range as.Balances {
+		acc.Balances[b.Asset] = []state.UnspentBalance{{Value: b.Value}}
+	}
+	return acc, nil
+}
+
+// GetAsset implements Backend interface.
+func (n *NeoCLIBackend) GetAsset(id util.Uint256, _ uint32) (*state.Asset, error) {
+	var as assetState
+	if err := n.client.Call(context.Background(), "getassetstate", []interface{}{"0x" + id.StringLE()}, &as); err != nil {
+		return nil, n.wrap("getassetstate", "asset "+id.StringLE(), err)
+	}
+	typ, ok := assetTypes[as.Type]
+	if !ok {
+		return nil, &Error{Method: "getassetstate", Err: fmt.Errorf("unknown asset type %s", as.Type)}
+	}
+	admin, err := address.StringToUint160(as.Admin)
+	if err != nil {
+		return nil, &Error{Method: "getassetstate", Err: err}
+	}
+	issuer, err := address.StringToUint160(as.Issuer)
+	if err != nil {
+		return nil, &Error{Method: "getassetstate", Err: err}
+	}
+	// The name is returned as a plain string or, if it's a JSON array of
+	// localized names, as that array, in which case it's kept as is.
+	var name string
+	if json.Unmarshal(as.Name, &name) != nil && string(as.Name) != "null" {
+		name = string(as.Name)
+	}
+	return &state.Asset{
+		ID:         as.ID,
+		AssetType:  typ,
+		Name:       name,
+		Amount:     as.Amount,
+		Available:  as.Available,
+		Precision:  as.Precision,
+		Owner:      as.Owner,
+		Admin:      admin,
+		Issuer:     issuer,
+		Expiration: as.Expiration,
+		IsFrozen:   as.Frozen,
+	}, nil
+}
+
+// GetValidators implements Backend interface. Active candidates are the
+// validators of the next block.
+func (n *NeoCLIBackend) GetValidators(_ uint32) (keys.PublicKeys, error) {
+	var vs []validatorState
+	if err := n.client.Call(context.Background(), "getvalidators", []interface{}{}, &vs); err != nil {
+		return nil, &Error{Method: "getvalidators", Err: err}
+	}
+	pubs := keys.PublicKeys{}
+	for _, v := range vs {
+		if !v.Active {
+			continue
+		}
+		pub, err := keys.NewPublicKeyFromString(v.PublicKey)
+		if err != nil {
+			return nil, &Error{Method: "getvalidators", Err: err}
+		}
+		pubs = append(pubs, pub)
+	}
+	sort.Sort(pubs)
+	return pubs, nil
+}
+
+// callBinary performs a call returning hex-encoded entity and decodes it.
+func (n *NeoCLIBackend) callBinary(method string, params []interface{}, s io.Serializable) error {
+	var str string
+	if err := n.client.Call(context.Background(), method, params, &str); err != nil {
+		return err
+	}
+	return decodeHex(str, s)
+}
+
+// wrap turns node errors about unknown entities into ErrNotFound and all the
+// other ones into Error.
+func (n *NeoCLIBackend) wrap(method string, what string, err error) error {
+	var rerr *rpc.Error
+	if errors.As(err, &rerr) && rerr.Code == errUnknown {
+		return fmt.Errorf("%s %w", what, ErrNotFound)
+	}
+	return &Error{Method: method, Err: err}
+}
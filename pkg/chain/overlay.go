@@ -0,0 +1,211 @@
+package chain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// Overlay is a writable view of contract storage on top of a Backend. Reads
+// from the backend are cached, writes (including deletions) are kept locally.
+// Nested layers created with Fork see the writes of their parents and can be
+// either committed into the parent or discarded.
+type Overlay struct {
+	backend Backend
+	height  uint32
+	parent  *Overlay
+	// reads caches backend items, nil is a cached miss. It's shared by all
+	// layers.
+	reads  map[string]*state.StorageItem
+	writes map[string]storageWrite
+}
+
+// storageWrite is a local write to the contract storage.
+type storageWrite struct {
+	hash util.Uint160
+	key  []byte
+	// item is nil for deleted keys.
+	item *state.StorageItem
+}
+
+// StorageChange is a net change of a single storage item made in the overlay.
+type StorageChange struct {
+	ScriptHash util.Uint160
+	Key        []byte
+	// Old is the backend item, nil if there was none.
+	Old *state.StorageItem
+	// New is the item after all writes, nil if it was deleted.
+	New *state.StorageItem
+}
+
+// NewOverlay creates an empty overlay over the backend storage at the given
+// height.
+func NewOverlay(b Backend, height uint32) *Overlay {
+	return &Overlay{
+		backend: b,
+		height:  height,
+		reads:   make(map[string]*state.StorageItem),
+		writes:  make(map[string]storageWrite),
+	}
+}
+
+// Fork creates a new layer on top of the overlay.
+func (o *Overlay) Fork() *Overlay {
+	return &Overlay{
+		backend: o.backend,
+		height:  o.height,
+		parent:  o,
+		reads:   o.reads,
+		writes:  make(map[string]storageWrite),
+	}
+}
+
+// Commit moves the writes of the layer into its parent. It does nothing for
+// the bottom layer.
+func (o *Overlay) Commit() {
+	if o.parent == nil {
+		return
+	}
+	for k, w := range o.writes {
+		o.parent.writes[k] = w
+	}
+	o.writes = make(map[string]storageWrite)
+}
+
+// Discard drops all writes made in the layer.
+func (o *Overlay) Discard() {
+	o.writes = make(map[string]storageWrite)
+}
+
+// Get returns the item stored by the contract under the given key. ErrNotFound
+// is returned for missing and deleted items.
+func (o *Overlay) Get(hash util.Uint160, key []byte) (*state.StorageItem, error) {
+	var si *state.StorageItem
+	w, ok := o.lookup(storageKey(hash, key))
+	if ok {
+		si = w.item
+	} else {
+		var err error
+		si, err = o.read(hash, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if si == nil {
+		return nil, fmt.Errorf("storage item %s/%x %w", hash.StringBE(), key, ErrNotFound)
+	}
+	return si, nil
+}
+
+// Put stores the item under the given key.
+func (o *Overlay) Put(hash util.Uint160, key []byte, si *state.StorageItem) {
+	o.writes[storageKey(hash, key)] = storageWrite{hash: hash, key: key, item: si}
+}
+
+// Delete removes the item stored under the given key.
+func (o *Overlay) Delete(hash util.Uint160, key []byte) {
+	o.writes[storageKey(hash, key)] = storageWrite{hash: hash, key: key}
+}
+
+// Find returns the contract storage items with the given key prefix. Items are
+// ordered the same way C# node returns them.
+func (o *Overlay) Find(hash util.Uint160, prefix []byte) ([]KeyValue, error) {
+	remote, err := o.backend.FindStorage(hash, prefix, o.height)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string]KeyValue, len(remote))
+	for _, kv := range remote {
+		items[storageKey(hash, kv.Key)] = kv
+	}
+	for k, w := range o.flatten() {
+		if !w.hash.Equals(hash) || !bytes.HasPrefix(w.key, prefix) {
+			continue
+		}
+		if w.item == nil {
+			delete(items, k)
+		} else {
+			items[k] = KeyValue{Key: w.key, Value: w.item.Value}
+		}
+	}
+	kvs := make([]KeyValue, 0, len(items))
+	for _, kv := range items {
+		kvs = append(kvs, kv)
+	}
+	SortKeyValues(kvs)
+	return kvs, nil
+}
+
+// Changes returns the net changes made in the overlay and all its parents,
+// ordered by contract hash and key. Writes that leave an item as it was in
+// the backend are still reported.
+func (o *Overlay) Changes() ([]StorageChange, error) {
+	writes := o.flatten()
+	res := make([]StorageChange, 0, len(writes))
+	for _, w := range writes {
+		old, err := o.read(w.hash, w.key)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, StorageChange{
+			ScriptHash: w.hash,
+			Key:        w.key,
+			Old:        old,
+			New:        w.item,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if c := bytes.Compare(res[i].ScriptHash.BytesBE(), res[j].ScriptHash.BytesBE()); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(res[i].Key, res[j].Key) < 0
+	})
+	return res, nil
+}
+
+// lookup returns the latest write to the key made in the overlay or its
+// parents.
+func (o *Overlay) lookup(k string) (storageWrite, bool) {
+	for l := o; l != nil; l = l.parent {
+		if w, ok := l.writes[k]; ok {
+			return w, true
+		}
+	}
+	return storageWrite{}, false
+}
+
+// flatten merges the writes of all layers.
+func (o *Overlay) flatten() map[string]storageWrite {
+	if o.parent == nil {
+		return o.writes
+	}
+	res := make(map[string]storageWrite)
+	for k, w := range o.parent.flatten() {
+		res[k] = w
+	}
+	for k, w := range o.writes {
+		res[k] = w
+	}
+	return res
+}
+
+// read returns the backend item, nil if there is none.
+func (o *Overlay) read(hash util.Uint160, key []byte) (*state.StorageItem, error) {
+	k := storageKey(hash, key)
+	if si, ok := o.reads[k]; ok {
+		return si, nil
+	}
+	si, err := o.backend.GetStorage(hash, key, o.height)
+	if errors.Is(err, ErrNotFound) {
+		si, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	o.reads[k] = si
+	return si, nil
+}
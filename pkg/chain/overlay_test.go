@@ -0,0 +1,107 @@
+package chain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// storageBackend is a Backend serving a single contract storage.
+type storageBackend struct {
+	Backend
+	hash  util.Uint160
+	items []KeyValue
+}
+
+func (b *storageBackend) GetStorage(hash util.Uint160, key []byte, _ uint32) (*state.StorageItem, error) {
+	for _, kv := range b.items {
+		if hash.Equals(b.hash) && string(kv.Key) == string(key) {
+			return &state.StorageItem{Value: kv.Value}, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (b *storageBackend) FindStorage(hash util.Uint160, prefix []byte, _ uint32) ([]KeyValue, error) {
+	var res []KeyValue
+	for _, kv := range b.items {
+		if hash.Equals(b.hash) && len(kv.Key) >= len(prefix) && string(kv.Key[:len(prefix)]) == string(prefix) {
+			res = append(res, kv)
+		}
+	}
+	return res, nil
+}
+
+func TestOverlayFind(t *testing.T) {
+	hash := util.Uint160{1, 2, 3}
+	b := &storageBackend{
+		hash: hash,
+		items: []KeyValue{
+			{Key: []byte("a1"), Value: []byte("remote1")},
+			{Key: []byte("a2"), Value: []byte("remote2")},
+			{Key: []byte("a3"), Value: []byte("remote3")},
+			{Key: []byte("b1"), Value: []byte("other")},
+		},
+	}
+	o := NewOverlay(b, 0)
+	o.Put(hash, []byte("a2"), &state.StorageItem{Value: []byte("local2")})
+	o.Put(hash, []byte("a"), &state.StorageItem{Value: []byte("local")})
+	o.Delete(hash, []byte("a3"))
+	o.Put(util.Uint160{4}, []byte("a4"), &state.StorageItem{Value: []byte("foreign")})
+
+	l := o.Fork()
+	l.Delete(hash, []byte("a1"))
+	l.Put(hash, []byte("a3"), &state.StorageItem{Value: []byte("revived")})
+	l.Put(hash, []byte("a5"), &state.StorageItem{Value: []byte("local5")})
+	l.Delete(hash, []byte("a5"))
+
+	var testCases = []struct {
+		name     string
+		overlay  *Overlay
+		expected []KeyValue
+	}{
+		{
+			name:    "bottom layer",
+			overlay: o,
+			expected: []KeyValue{
+				{Key: []byte("a"), Value: []byte("local")},
+				{Key: []byte("a1"), Value: []byte("remote1")},
+				{Key: []byte("a2"), Value: []byte("local2")},
+			},
+		},
+		{
+			name:    "forked layer",
+			overlay: l,
+			expected: []KeyValue{
+				{Key: []byte("a"), Value: []byte("local")},
+				{Key: []byte("a2"), Value: []byte("local2")},
+				{Key: []byte("a3"), Value: []byte("revived")},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		kvs, err := tc.overlay.Find(hash, []byte("a"))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(kvs) != len(tc.expected) {
+			t.Fatalf("%s: expected %d items, got %d", tc.name, len(tc.expected), len(kvs))
+		}
+		for i, kv := range kvs {
+			if string(kv.Key) != string(tc.expected[i].Key) || string(kv.Value) != string(tc.expected[i].Value) {
+				t.Errorf("%s: expected %s=%s at %d, got %s=%s", tc.name,
+					tc.expected[i].Key, tc.expected[i].Value, i, kv.Key, kv.Value)
+			}
+		}
+	}
+
+	l.Discard()
+	if _, err := l.Get(hash, []byte("a1")); err != nil {
+		t.Errorf("discarded deletion is still visible: %v", err)
+	}
+	if _, err := o.Get(hash, []byte("a3")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted item is visible: %v", err)
+	}
+}
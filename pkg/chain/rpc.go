@@ -0,0 +1,288 @@
+package chain
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/block"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neo-go/pkg/io"
+	"github.com/nspcc-dev/neo-go/pkg/rpc"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// RPCBackend is a Backend talking to a node via the custom JSON-RPC dialect
+// (GetContractByContractHashBlockHeightInHex and friends).
+type RPCBackend struct {
+	client *rpc.Client
+	// bareStorage is set once the node is known to return bare storage
+	// values only.
+	bareStorage int32
+}
+
+// NewRPCBackend returns a new RPCBackend using the given client.
+func NewRPCBackend(c *rpc.Client) *RPCBackend {
+	return &RPCBackend{client: c}
+}
+
+// HasHistory implements Backend interface.
+func (r *RPCBackend) HasHistory() bool {
+	return true
+}
+
+// GetHeight implements Backend interface.
+func (r *RPCBackend) GetHeight() (uint32, error) {
+	height, err := r.client.GetCurrentBlockHeight(context.Background())
+	if err != nil {
+		return 0, &Error{Method: "GetCurrentBlockHeightInUint64", Err: err}
+	}
+	return height, nil
+}
+
+// GetBlockHash implements Backend interface.
+func (r *RPCBackend) GetBlockHash(index uint32) (util.Uint256, error) {
+	str, err := r.client.GetBlockHashByHeight(context.Background(), index)
+	if err == nil {
+		var hash util.Uint256
+		hash, err = util.Uint256DecodeStringBE(str)
+		if err == nil {
+			return hash, nil
+		}
+	}
+	return util.Uint256{}, &Error{Method: "GetBlockHashByBlockHeightInHex", Err: err}
+}
+
+// GetBlock implements Backend interface.
+func (r *RPCBackend) GetBlock(hash util.Uint256) (*block.Block, error) {
+	b, err := r.client.GetBlockByHash(context.Background(), hash)
+	if err == nil {
+		blk := new(block.Block)
+		if err = decodeBinary(b, blk); err == nil {
+			return blk, nil
+		}
+	}
+	return nil, &Error{Method: "GetBlockByBlockHashInHex", Err: err}
+}
+
+// GetHeader implements Backend interface.
+func (r *RPCBackend) GetHeader(hash util.Uint256) (*block.Header, error) {
+	b, err := r.client.GetHeaderByHash(context.Background(), hash)
+	if err == nil {
+		hd := new(block.Header)
+		if err = decodeBinary(b, hd); err == nil {
+			return hd, nil
+		}
+	}
+	return nil, &Error{Method: "GetHeaderByBlockHashInHex", Err: err}
+}
+
+// GetHeaderByIndex implements Backend interface.
+func (r *RPCBackend) GetHeaderByIndex(index uint32) (*block.Header, error) {
+	b, err := r.client.GetHeaderByHeight(context.Background(), index)
+	if err == nil {
+		hd := new(block.Header)
+		if err = decodeBinary(b, hd); err == nil {
+			return hd, nil
+		}
+	}
+	return nil, &Error{Method: "GetHeaderByBlockHeightInHex", Err: err}
+}
+
+// GetTransaction implements Backend interface. The custom dialect doesn't
+// report transaction height, so it's always returned as UnknownHeight.
+func (r *RPCBackend) GetTransaction(hash util.Uint256) (*transaction.Transaction, uint32, error) {
+	raw, err := r.client.GetTransaction(context.Background(), hash)
+	if err == nil {
+		tx := new(transaction.Transaction)
+		// Both hex-encoded and JSON-formatted transactions are accepted here.
+		var str string
+		if json.Unmarshal(raw, &str) == nil {
+			err = decodeHex(str, tx)
+		} else {
+			err = tx.UnmarshalJSON(raw)
+		}
+		if err == nil {
+			return tx, UnknownHeight, nil
+		}
+	}
+	return nil, 0, &Error{Method: "Data.GetTransactionByHashInHex", Err: err}
+}
+
+// GetContract implements Backend interface.
+func (r *RPCBackend) GetContract(hash util.Uint160, height uint32) (*state.Contract, error) {
+	b, err := r.client.GetContract(context.Background(), hash, height)
+	if err != nil {
+		return nil, &Error{Method: "GetContractByContractHashBlockHeightInHex", Err: err}
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("contract %s %w", hash.StringBE(), ErrNotFound)
+	}
+	cs := new(state.Contract)
+	// The first byte is a storage prefix.
+	if err := decodeBinary(b[1:], cs); err != nil {
+		return nil, &Error{Method: "GetContractByContractHashBlockHeightInHex", Err: err}
+	}
+	return cs, nil
+}
+
+// GetStorage implements Backend interface. Nodes that can't return the whole
+// item are asked for a bare value, such items are never reported as constant.
+func (r *RPCBackend) GetStorage(hash util.Uint160, key []byte, height uint32) (*state.StorageItem, error) {
+	if atomic.LoadInt32(&r.bareStorage) == 0 {
+		b, err := r.client.GetStorageItem(context.Background(), hash, key, height)
+		var rerr *rpc.Error
+		switch {
+		case errors.As(err, &rerr):
+			// Nodes reject unknown methods in different ways, any
+			// error reply means the extension isn't there.
+			atomic.StoreInt32(&r.bareStorage, 1)
+		case err != nil:
+			return nil, &Error{Method: "GetStorageItemByContractHashHexKeyBlockHeightInHex", Err: err}
+		case len(b) == 0:
+			return nil, fmt.Errorf("storage item %s/%x %w", hash.StringBE(), key, ErrNotFound)
+		default:
+			si := new(state.StorageItem)
+			// The first byte is a version.
+			if err := decodeBinary(b[1:], si); err != nil {
+				return nil, &Error{Method: "GetStorageItemByContractHashHexKeyBlockHeightInHex", Err: err}
+			}
+			return si, nil
+		}
+	}
+	b, err := r.client.GetStorage(context.Background(), hash, key, height)
+	if err != nil {
+		return nil, &Error{Method: "GetStorageByContractHashHexKeyBlockHeightInHex", Err: err}
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("storage item %s/%x %w", hash.StringBE(), key, ErrNotFound)
+	}
+	return &state.StorageItem{Value: b}, nil
+}
+
+// FindStorage implements Backend interface. The node is expected to return
+// an object mapping hex-encoded keys to hex-encoded values.
+func (r *RPCBackend) FindStorage(hash util.Uint160, prefix []byte, height uint32) ([]KeyValue, error) {
+	items, err := r.client.FindStorage(context.Background(), hash, prefix, height)
+	if err != nil {
+		return nil, &Error{Method: "FindStorageByContractHashHexPrefixBlockHeightInHex", Err: err}
+	}
+	kvs := make([]KeyValue, 0, len(items))
+	for k, v := range items {
+		key, err := hex.DecodeString(k)
+		if err != nil {
+			return nil, &Error{Method: "FindStorageByContractHashHexPrefixBlockHeightInHex", Err: err}
+		}
+		val, err := hex.DecodeString(v)
+		if err != nil {
+			return nil, &Error{Method: "FindStorageByContractHashHexPrefixBlockHeightInHex", Err: err}
+		}
+		kvs = append(kvs, KeyValue{Key: key, Value: val})
+	}
+	return kvs, nil
+}
+
+// GetAccount implements Backend interface.
+func (r *RPCBackend) GetAccount(hash util.Uint160, height uint32) (*state.Account, error) {
+	b, err := r.client.GetAccount(context.Background(), hash, height)
+	if err != nil {
+		return nil, &Error{Method: "GetAccountByAccountHashBlockHeightInHex", Err: err}
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("account %s %w", hash.StringBE(), ErrNotFound)
+	}
+	acc, err := decodeAccountState(b)
+	if err != nil {
+		return nil, &Error{Method: "GetAccountByAccountHashBlockHeightInHex", Err: err}
+	}
+	return acc, nil
+}
+
+// decodeAccountState decodes account state in C# node format which keeps
+// only the total balance for every asset.
+func decodeAccountState(b []byte) (*state.Account, error) {
+	r := io.NewBinReaderFromBuf(b)
+	acc := state.NewAccount(util.Uint160{})
+	acc.Version = r.ReadB()
+	r.ReadBytes(acc.ScriptHash[:])
+	acc.IsFrozen = r.ReadBool()
+	r.ReadArray(&acc.Votes)
+	n := r.ReadVarUint()
+	for i := uint64(0); i < n && r.Err == nil; i++ {
+		var asset util.Uint256
+		var value util.Fixed8
+		r.ReadBytes(asset[:])
+		value.DecodeBinary(r)
+		acc.Balances[asset] = []state.UnspentBalance{{Value: value}}
+	}
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return acc, nil
+}
+
+// GetAsset implements Backend interface.
+func (r *RPCBackend) GetAsset(id util.Uint256, height uint32) (*state.Asset, error) {
+	b, err := r.client.GetAsset(context.Background(), id, height)
+	if err != nil {
+		return nil, &Error{Method: "GetAssetByAssetHashBlockHeightInHex", Err: err}
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("asset %s %w", id.StringLE(), ErrNotFound)
+	}
+	a, err := decodeAssetState(b)
+	if err != nil {
+		return nil, &Error{Method: "GetAssetByAssetHashBlockHeightInHex", Err: err}
+	}
+	return a, nil
+}
+
+// decodeAssetState decodes asset state in C# node format which has a version
+// prefix and an (unused) fee field.
+func decodeAssetState(b []byte) (*state.Asset, error) {
+	r := io.NewBinReaderFromBuf(b)
+	a := new(state.Asset)
+	r.ReadB()
+	r.ReadBytes(a.ID[:])
+	a.AssetType = transaction.AssetType(r.ReadB())
+	a.Name = r.ReadString()
+	a.Amount.DecodeBinary(r)
+	a.Available.DecodeBinary(r)
+	a.Precision = r.ReadB()
+	a.FeeMode = r.ReadB()
+	var fee util.Fixed8
+	fee.DecodeBinary(r)
+	r.ReadBytes(a.FeeAddress[:])
+	a.Owner.DecodeBinary(r)
+	r.ReadBytes(a.Admin[:])
+	r.ReadBytes(a.Issuer[:])
+	a.Expiration = r.ReadU32LE()
+	a.IsFrozen = r.ReadBool()
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return a, nil
+}
+
+// GetValidators implements Backend interface. The node is expected to return
+// a list of hex-encoded compressed public keys.
+func (r *RPCBackend) GetValidators(height uint32) (keys.PublicKeys, error) {
+	strs, err := r.client.GetValidators(context.Background(), height)
+	if err != nil {
+		return nil, &Error{Method: "GetValidatorsByBlockHeightInHex", Err: err}
+	}
+	pubs := make(keys.PublicKeys, 0, len(strs))
+	for _, str := range strs {
+		pub, err := keys.NewPublicKeyFromString(str)
+		if err != nil {
+			return nil, &Error{Method: "GetValidatorsByBlockHeightInHex", Err: err}
+		}
+		pubs = append(pubs, pub)
+	}
+	return pubs, nil
+}
@@ -0,0 +1,95 @@
+package chain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/io"
+	"github.com/nspcc-dev/neo-go/pkg/rpc"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// newTestRPCBackend returns an RPCBackend talking to a server replying with
+// the result of handle for every request, it's either a result or an error.
+func newTestRPCBackend(t *testing.T, handle func(method string) (result, rerr interface{})) (*RPCBackend, *httptest.Server) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("can't read request: %v", err)
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		result, rerr := handle(req.Method)
+		if rerr != nil {
+			resp["error"] = rerr
+		} else {
+			resp["result"] = result
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	c, err := rpc.New([]string{s.URL}, rpc.Options{})
+	if err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+	return NewRPCBackend(c), s
+}
+
+func TestRPCBackendGetStorage(t *testing.T) {
+	item := &state.StorageItem{Value: []byte{1, 2, 3}, IsConst: true}
+	w := io.NewBufBinWriter()
+	w.WriteB(0) // version
+	item.EncodeBinary(w.BinWriter)
+	serialized := hex.EncodeToString(w.Bytes())
+
+	var testCases = []struct {
+		name     string
+		rerr     interface{}
+		expected *state.StorageItem
+		// calls is the number of requests to the extension.
+		calls int
+	}{
+		{"extension", nil, item, 2},
+		{"method not found", map[string]interface{}{"code": -32601, "message": "Method not found"}, &state.StorageItem{Value: item.Value}, 1},
+		{"other code", map[string]interface{}{"code": -32600, "message": "Invalid request"}, &state.StorageItem{Value: item.Value}, 1},
+		{"plain string", "unknown method", &state.StorageItem{Value: item.Value}, 1},
+	}
+	for _, tc := range testCases {
+		var calls int
+		b, s := newTestRPCBackend(t, func(method string) (interface{}, interface{}) {
+			switch method {
+			case "GetStorageItemByContractHashHexKeyBlockHeightInHex":
+				calls++
+				if tc.rerr != nil {
+					return nil, tc.rerr
+				}
+				return serialized, nil
+			case "GetStorageByContractHashHexKeyBlockHeightInHex":
+				return hex.EncodeToString(item.Value), nil
+			}
+			t.Errorf("%s: unexpected method %s", tc.name, method)
+			return nil, "unexpected"
+		})
+		for i := 0; i < 2; i++ {
+			si, err := b.GetStorage(util.Uint160{1}, []byte{2}, 10)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			if string(si.Value) != string(tc.expected.Value) || si.IsConst != tc.expected.IsConst {
+				t.Errorf("%s: expected %x (const %t), got %x (const %t)", tc.name,
+					tc.expected.Value, tc.expected.IsConst, si.Value, si.IsConst)
+			}
+		}
+		// The extension isn't asked for again once it's known to be absent.
+		if calls != tc.calls {
+			t.Errorf("%s: expected %d extension calls, got %d", tc.name, tc.calls, calls)
+		}
+		s.Close()
+	}
+}
@@ -0,0 +1,54 @@
+package chain
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/nspcc-dev/neo-go/pkg/io"
+)
+
+// storageKeyGroupSize is the group size used by C# node to serialize storage
+// keys (see WriteBytesWithGrouping).
+const storageKeyGroupSize = 16
+
+// KeyValue is a single contract storage item returned by FindStorage.
+type KeyValue struct {
+	Key   []byte
+	Value []byte
+}
+
+// EncodeBinary implements io.Serializable interface.
+func (kv *KeyValue) EncodeBinary(w *io.BinWriter) {
+	w.WriteVarBytes(kv.Key)
+	w.WriteVarBytes(kv.Value)
+}
+
+// DecodeBinary implements io.Serializable interface.
+func (kv *KeyValue) DecodeBinary(r *io.BinReader) {
+	kv.Key = r.ReadVarBytes()
+	kv.Value = r.ReadVarBytes()
+}
+
+// SortKeyValues sorts storage items in the same order C# node iterates over
+// them in Neo.Storage.Find, that is by their grouped key serialization.
+func SortKeyValues(kvs []KeyValue) {
+	sort.Slice(kvs, func(i, j int) bool {
+		return bytes.Compare(groupKey(kvs[i].Key), groupKey(kvs[j].Key)) < 0
+	})
+}
+
+// groupKey returns key serialized the way C# node does it for its storage:
+// every 16-byte group is followed by a zero byte and the last (possibly
+// empty) group is zero-padded and followed by the padding length.
+func groupKey(key []byte) []byte {
+	res := make([]byte, 0, (len(key)/storageKeyGroupSize+1)*(storageKeyGroupSize+1))
+	for len(key) >= storageKeyGroupSize {
+		res = append(res, key[:storageKeyGroupSize]...)
+		res = append(res, 0)
+		key = key[storageKeyGroupSize:]
+	}
+	padding := storageKeyGroupSize - len(key)
+	res = append(res, key...)
+	res = append(res, make([]byte, padding)...)
+	return append(res, byte(padding))
+}
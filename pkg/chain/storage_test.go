@@ -0,0 +1,81 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestGroupKey(t *testing.T) {
+	var testCases = []struct {
+		key      string
+		expected string
+	}{
+		{"", strings.Repeat("00", 16) + "10"},
+		{strings.Repeat("01", 15), strings.Repeat("01", 15) + "00" + "01"},
+		{strings.Repeat("01", 16), strings.Repeat("01", 16) + "00" + strings.Repeat("00", 16) + "10"},
+		{strings.Repeat("01", 17), strings.Repeat("01", 16) + "00" + "01" + strings.Repeat("00", 15) + "0f"},
+		{strings.Repeat("01", 32), strings.Repeat("01", 16) + "00" + strings.Repeat("01", 16) + "00" + strings.Repeat("00", 16) + "10"},
+	}
+	for _, tc := range testCases {
+		key, _ := hex.DecodeString(tc.key)
+		actual := hex.EncodeToString(groupKey(key))
+		if actual != tc.expected {
+			t.Errorf("key %q: expected %s, got %s", tc.key, tc.expected, actual)
+		}
+	}
+}
+
+func TestSortKeyValues(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		keys     []string
+		expected []string
+	}{
+		{
+			name:     "empty key",
+			keys:     []string{"01", "", "00"},
+			expected: []string{"00", "", "01"},
+		},
+		{
+			name:     "longer key with zero suffix first",
+			keys:     []string{"61", "6100", "610000"},
+			expected: []string{"610000", "6100", "61"},
+		},
+		{
+			name: "group boundary",
+			keys: []string{
+				strings.Repeat("01", 16),
+				strings.Repeat("01", 17),
+				strings.Repeat("01", 15),
+				strings.Repeat("01", 16) + "00",
+				strings.Repeat("01", 32),
+			},
+			expected: []string{
+				strings.Repeat("01", 15),
+				strings.Repeat("01", 16) + "00",
+				strings.Repeat("01", 16),
+				strings.Repeat("01", 17),
+				strings.Repeat("01", 32),
+			},
+		},
+		{
+			name:     "plain bytes order",
+			keys:     []string{"02", "0101", "01ff"},
+			expected: []string{"0101", "01ff", "02"},
+		},
+	}
+	for _, tc := range testCases {
+		kvs := make([]KeyValue, len(tc.keys))
+		for i, k := range tc.keys {
+			kvs[i].Key, _ = hex.DecodeString(k)
+		}
+		SortKeyValues(kvs)
+		for i, kv := range kvs {
+			if expected, _ := hex.DecodeString(tc.expected[i]); !bytes.Equal(kv.Key, expected) {
+				t.Errorf("%s: expected %s at %d, got %x", tc.name, tc.expected[i], i, kv.Key)
+			}
+		}
+	}
+}
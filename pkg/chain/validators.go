@@ -0,0 +1,44 @@
+package chain
+
+import (
+	"sort"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+)
+
+// ComputeValidators returns validators elected by the given votes the same
+// way C# node does it. Standby validators fill the list if there are not
+// enough registered candidates with votes.
+func ComputeValidators(standby keys.PublicKeys, validators []*state.Validator, count *state.ValidatorsCount) keys.PublicKeys {
+	n := count.GetWeightedAverage()
+	if n < len(standby) {
+		n = len(standby)
+	}
+	candidates := make([]*state.Validator, 0, len(validators))
+	for _, v := range validators {
+		if v.RegisteredAndHasVotes() || standby.Contains(v.PublicKey) {
+			candidates = append(candidates, v)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Votes != candidates[j].Votes {
+			return candidates[i].Votes > candidates[j].Votes
+		}
+		return candidates[i].PublicKey.Cmp(candidates[j].PublicKey) == -1
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	result := make(keys.PublicKeys, 0, n)
+	for _, v := range candidates {
+		result = append(result, v.PublicKey)
+	}
+	for i := 0; i < len(standby) && len(result) < n; i++ {
+		if !result.Contains(standby[i]) {
+			result = append(result, standby[i])
+		}
+	}
+	sort.Sort(result)
+	return result
+}
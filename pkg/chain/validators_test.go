@@ -0,0 +1,117 @@
+package chain
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+func TestComputeValidators(t *testing.T) {
+	// Keys are sorted, so k[i] < k[j] for i < j.
+	k := make(keys.PublicKeys, 10)
+	for i := range k {
+		priv, err := keys.NewPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		k[i] = priv.PublicKey()
+	}
+	sort.Sort(k)
+	standby := keys.PublicKeys{k[9], k[8], k[7], k[6]}
+
+	// votedFor returns ValidatorsCount with the given number of votes for
+	// each validators count.
+	votedFor := func(votes map[int]int64) *state.ValidatorsCount {
+		vc := new(state.ValidatorsCount)
+		for n, v := range votes {
+			vc[n-1] = util.Fixed8FromInt64(v)
+		}
+		return vc
+	}
+	candidate := func(key *keys.PublicKey, votes util.Fixed8) *state.Validator {
+		return &state.Validator{PublicKey: key, Registered: true, Votes: votes}
+	}
+
+	var testCases = []struct {
+		name       string
+		validators []*state.Validator
+		count      *state.ValidatorsCount
+		expected   keys.PublicKeys
+	}{
+		{
+			name:     "standby only",
+			count:    votedFor(nil),
+			expected: keys.PublicKeys{k[6], k[7], k[8], k[9]},
+		},
+		{
+			name: "filled from standby",
+			validators: []*state.Validator{
+				candidate(k[0], 10),
+				// Unregistered ones and ones without votes aren't
+				// candidates.
+				{PublicKey: k[1], Votes: 20},
+				candidate(k[2], 0),
+			},
+			count:    votedFor(nil),
+			expected: keys.PublicKeys{k[0], k[7], k[8], k[9]},
+		},
+		{
+			name: "ordered by votes then key",
+			validators: []*state.Validator{
+				candidate(k[5], 10),
+				candidate(k[4], 30),
+				candidate(k[3], 10),
+				candidate(k[2], 20),
+				candidate(k[1], 5),
+				candidate(k[0], 10),
+			},
+			count:    votedFor(nil),
+			expected: keys.PublicKeys{k[0], k[2], k[3], k[4]},
+		},
+		{
+			name: "weighted count",
+			validators: []*state.Validator{
+				candidate(k[0], 10),
+				candidate(k[1], 10),
+			},
+			// The weighted median of 5 (1 vote) and 7 (3 votes) is 7.
+			count:    votedFor(map[int]int64{5: 1, 7: 3}),
+			expected: keys.PublicKeys{k[0], k[1], k[6], k[7], k[8], k[9]},
+		},
+		{
+			name: "weighted count rounded down",
+			validators: []*state.Validator{
+				candidate(k[0], 10),
+				candidate(k[1], 20),
+				candidate(k[2], 30),
+			},
+			// The weighted average of 6 and 7 is 6.5.
+			count:    votedFor(map[int]int64{6: 1, 7: 1}),
+			expected: keys.PublicKeys{k[0], k[1], k[2], k[7], k[8], k[9]},
+		},
+		{
+			name: "count below standby",
+			validators: []*state.Validator{
+				candidate(k[0], 10),
+				candidate(k[1], 20),
+			},
+			count:    votedFor(map[int]int64{1: 1}),
+			expected: keys.PublicKeys{k[0], k[1], k[8], k[9]},
+		},
+	}
+	for _, tc := range testCases {
+		actual := ComputeValidators(standby, tc.validators, tc.count)
+		if len(actual) != len(tc.expected) {
+			t.Errorf("%s: expected %d validators, got %d", tc.name, len(tc.expected), len(actual))
+			continue
+		}
+		for i := range actual {
+			if !actual[i].Equal(tc.expected[i]) {
+				t.Errorf("%s: expected %s at %d, got %s", tc.name, tc.expected[i].Address(), i, actual[i].Address())
+			}
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package state
 
 import (
+	"encoding/json"
+
 	"github.com/nspcc-dev/neo-go/pkg/io"
 	"github.com/nspcc-dev/neo-go/pkg/smartcontract"
 	"github.com/nspcc-dev/neo-go/pkg/smartcontract/trigger"
@@ -26,6 +28,23 @@ type AppExecResult struct {
 	Events      []NotificationEvent
 }
 
+// notificationEventAux is an auxiliary struct for NotificationEvent JSON
+// marshalling.
+type notificationEventAux struct {
+	ScriptHash util.Uint160            `json:"contract"`
+	Item       smartcontract.Parameter `json:"state"`
+}
+
+// appExecResultAux is an auxiliary struct for AppExecResult JSON marshalling.
+type appExecResultAux struct {
+	TxHash      util.Uint256              `json:"txid"`
+	Trigger     string                    `json:"trigger"`
+	VMState     string                    `json:"vmstate"`
+	GasConsumed util.Fixed8               `json:"gas_consumed"`
+	Stack       []smartcontract.Parameter `json:"stack"`
+	Events      []NotificationEvent       `json:"notifications"`
+}
+
 // EncodeBinary implements the Serializable interface.
 func (ne *NotificationEvent) EncodeBinary(w *io.BinWriter) {
 	w.WriteBytes(ne.ScriptHash[:])
@@ -57,3 +76,31 @@ func (aer *AppExecResult) DecodeBinary(r *io.BinReader) {
 	r.ReadArray(&aer.Stack)
 	r.ReadArray(&aer.Events)
 }
+
+// MarshalJSON implements json.Marshaler interface.
+func (ne *NotificationEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&notificationEventAux{
+		ScriptHash: ne.ScriptHash,
+		Item:       ne.Item.ToContractParameter(map[vm.StackItem]bool{}),
+	})
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (aer *AppExecResult) MarshalJSON() ([]byte, error) {
+	stack := aer.Stack
+	if stack == nil {
+		stack = []smartcontract.Parameter{}
+	}
+	events := aer.Events
+	if events == nil {
+		events = []NotificationEvent{}
+	}
+	return json.Marshal(&appExecResultAux{
+		TxHash:      aer.TxHash,
+		Trigger:     aer.Trigger.String(),
+		VMState:     aer.VMState,
+		GasConsumed: aer.GasConsumed,
+		Stack:       stack,
+		Events:      events,
+	})
+}
@@ -0,0 +1,259 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default client options.
+const (
+	DefaultTimeout = 10 * time.Second
+	DefaultRetries = 3
+	DefaultBackoff = 100 * time.Millisecond
+)
+
+// Options are the Client settings. Zero Timeout and Backoff are replaced with
+// defaults.
+type Options struct {
+	// Timeout is the time limit of a single attempt to perform a request.
+	Timeout time.Duration
+	// Retries is the number of times a failed request is retried, zero or
+	// negative value disables retries. Every retry goes to the next endpoint.
+	Retries int
+	// Backoff is the delay before the first retry, it's doubled for every
+	// subsequent one.
+	Backoff time.Duration
+}
+
+// Client is a JSON-RPC client for the custom chain dialect. It's safe for
+// concurrent use.
+type Client struct {
+	endpoints []string
+	opts      Options
+	http      *http.Client
+	id        uint64
+
+	lock sync.Mutex
+	// current is the index of the endpoint requests are sent to.
+	current int
+}
+
+// Request is a single request of a batch. Result is unmarshaled into, Err is
+// set if the request has failed.
+type Request struct {
+	Method string
+	Params interface{}
+	Result interface{}
+	Err    error
+}
+
+// Error is a JSON-RPC error returned by the node. Requests failed with it are
+// not retried.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// ErrNoResult is returned when the node responds with neither result nor error.
+var ErrNoResult = errors.New("no result")
+
+// request is a JSON-RPC request envelope.
+type request struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      uint64      `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// response is a JSON-RPC response envelope.
+type response struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// New returns a new Client sending requests to the given endpoints. The first
+// one is used until it fails.
+func New(endpoints []string, opts Options) (*Client, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("no endpoints")
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Backoff == 0 {
+		opts.Backoff = DefaultBackoff
+	}
+	return &Client{
+		endpoints: endpoints,
+		opts:      opts,
+		http:      &http.Client{},
+	}, nil
+}
+
+func (e *Error) Error() string {
+	if len(e.Data) != 0 {
+		return fmt.Sprintf("%s (%d): %s", e.Message, e.Code, e.Data)
+	}
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface. Errors given as plain
+// strings are accepted too.
+func (e *Error) UnmarshalJSON(data []byte) error {
+	var msg string
+	if json.Unmarshal(data, &msg) == nil {
+		e.Message = msg
+		return nil
+	}
+	type plain Error
+	return json.Unmarshal(data, (*plain)(e))
+}
+
+// Call performs a request and unmarshals its result into res.
+func (c *Client) Call(ctx context.Context, method string, params interface{}, res interface{}) error {
+	req := &Request{Method: method, Params: params, Result: res}
+	if err := c.Batch(ctx, []*Request{req}); err != nil {
+		return err
+	}
+	return req.Err
+}
+
+// Batch performs all requests in a single JSON-RPC batch. The error returned
+// is a failure of the batch as a whole, errors of individual requests are
+// stored in them.
+func (c *Client) Batch(ctx context.Context, reqs []*Request) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+	envs := make([]request, len(reqs))
+	byID := make(map[uint64]*Request, len(reqs))
+	for i, r := range reqs {
+		id := atomic.AddUint64(&c.id, 1)
+		envs[i] = request{JSONRPC: "2.0", ID: id, Method: r.Method, Params: r.Params}
+		byID[id] = r
+	}
+	var body []byte
+	var err error
+	// A single request isn't sent as a batch, some nodes don't support them.
+	if len(envs) == 1 {
+		body, err = json.Marshal(envs[0])
+	} else {
+		body, err = json.Marshal(envs)
+	}
+	if err != nil {
+		return err
+	}
+	resps, err := c.send(ctx, body, len(envs) > 1)
+	if err != nil {
+		return err
+	}
+	if len(envs) == 1 {
+		resps[0].ID = envs[0].ID
+	}
+	for _, resp := range resps {
+		r, ok := byID[resp.ID]
+		if !ok {
+			continue
+		}
+		delete(byID, resp.ID)
+		log.Println("[RESP]", r.Method, string(resp.Result))
+		switch {
+		case resp.Error != nil:
+			r.Err = resp.Error
+		case len(resp.Result) == 0:
+			r.Err = ErrNoResult
+		default:
+			r.Err = json.Unmarshal(resp.Result, r.Result)
+		}
+	}
+	for _, r := range byID {
+		r.Err = ErrNoResult
+	}
+	return nil
+}
+
+// send posts the body retrying on failures and returns the responses.
+func (c *Client) send(ctx context.Context, body []byte, batch bool) ([]response, error) {
+	log.Println("[REQ]", string(body))
+	var err error
+	backoff := c.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		endpoint := c.endpoint()
+		var resps []response
+		resps, err = c.post(ctx, endpoint, body, batch)
+		if err == nil {
+			return resps, nil
+		}
+		log.Println("[RPC]", endpoint, err)
+		if attempt >= c.opts.Retries || ctx.Err() != nil {
+			return nil, err
+		}
+		c.failover(endpoint)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// post makes a single attempt to post the body to the endpoint.
+func (c *Client) post(ctx context.Context, endpoint string, body []byte, batch bool) ([]response, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, errors.New("HTTP " + resp.Status)
+	}
+	var resps []response
+	if batch {
+		err = json.NewDecoder(resp.Body).Decode(&resps)
+	} else {
+		resps = make([]response, 1)
+		err = json.NewDecoder(resp.Body).Decode(&resps[0])
+	}
+	if err != nil {
+		if resp.StatusCode != http.StatusOK {
+			err = errors.New("HTTP " + resp.Status)
+		}
+		return nil, err
+	}
+	return resps, nil
+}
+
+// endpoint returns the endpoint to send requests to.
+func (c *Client) endpoint() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.endpoints[c.current]
+}
+
+// failover switches to the next endpoint unless it's already done by another
+// failed request.
+func (c *Client) failover(failed string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.endpoints[c.current] == failed {
+		c.current = (c.current + 1) % len(c.endpoints)
+	}
+}
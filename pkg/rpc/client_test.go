@@ -0,0 +1,219 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testServer is a JSON-RPC stand-in counting requests it gets.
+type testServer struct {
+	*httptest.Server
+	calls int32
+}
+
+// newTestServer starts a server replying with the result of handle for every
+// request. handle gets the number of the request (starting from 1) and its
+// body.
+func newTestServer(t *testing.T, handle func(n int, w http.ResponseWriter, body []byte)) *testServer {
+	s := new(testServer)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("can't read request: %v", err)
+		}
+		handle(int(atomic.AddInt32(&s.calls, 1)), w, body)
+	}))
+	return s
+}
+
+func (s *testServer) Calls() int {
+	return int(atomic.LoadInt32(&s.calls))
+}
+
+// reply writes a response with the given result to the single request.
+func reply(w http.ResponseWriter, body []byte, result string) {
+	var req request
+	_ = json.Unmarshal(body, &req)
+	resp, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      req.ID,
+		"result":  json.RawMessage(result),
+	})
+	_, _ = w.Write(resp)
+}
+
+func newTestClient(t *testing.T, opts Options, endpoints ...string) *Client {
+	c, err := New(endpoints, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClientTimeout(t *testing.T) {
+	s := newTestServer(t, func(n int, w http.ResponseWriter, body []byte) {
+		if n == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		reply(w, body, "42")
+	})
+	defer s.Close()
+
+	c := newTestClient(t, Options{Timeout: 50 * time.Millisecond, Retries: 0}, s.URL)
+	var res int
+	if err := c.Call(context.Background(), "test", []interface{}{}, &res); err == nil {
+		t.Fatal("expected timeout error")
+	}
+
+	c = newTestClient(t, Options{Timeout: 50 * time.Millisecond, Retries: 1, Backoff: time.Millisecond}, s.URL)
+	if err := c.Call(context.Background(), "test", []interface{}{}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res != 42 {
+		t.Errorf("expected 42, got %d", res)
+	}
+}
+
+func TestClientRetryBackoff(t *testing.T) {
+	s := newTestServer(t, func(n int, w http.ResponseWriter, body []byte) {
+		if n <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		reply(w, body, `"ok"`)
+	})
+	defer s.Close()
+
+	c := newTestClient(t, Options{Retries: 2, Backoff: 20 * time.Millisecond}, s.URL)
+	var res string
+	start := time.Now()
+	if err := c.Call(context.Background(), "test", []interface{}{}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("expected at least 20ms+40ms of backoff, got %s", elapsed)
+	}
+	if res != "ok" || s.Calls() != 3 {
+		t.Errorf("expected ok after 3 calls, got %q after %d", res, s.Calls())
+	}
+
+	c = newTestClient(t, Options{Retries: 1, Backoff: time.Millisecond}, s.URL)
+	atomic.StoreInt32(&s.calls, 0)
+	if err := c.Call(context.Background(), "test", []interface{}{}, &res); err == nil {
+		t.Fatal("expected error after retries are exhausted")
+	}
+	if s.Calls() != 2 {
+		t.Errorf("expected 2 calls, got %d", s.Calls())
+	}
+}
+
+func TestClientFailover(t *testing.T) {
+	bad := newTestServer(t, func(_ int, w http.ResponseWriter, _ []byte) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	defer bad.Close()
+	good := newTestServer(t, func(_ int, w http.ResponseWriter, body []byte) {
+		reply(w, body, "1")
+	})
+	defer good.Close()
+
+	c := newTestClient(t, Options{Retries: 1, Backoff: time.Millisecond}, bad.URL, good.URL)
+	var res int
+	for i := 0; i < 2; i++ {
+		if err := c.Call(context.Background(), "test", []interface{}{}, &res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The second request goes to the good endpoint right away.
+	if bad.Calls() != 1 || good.Calls() != 2 {
+		t.Errorf("expected 1 and 2 calls, got %d and %d", bad.Calls(), good.Calls())
+	}
+}
+
+func TestClientNoRetryOnError(t *testing.T) {
+	s := newTestServer(t, func(_ int, w http.ResponseWriter, body []byte) {
+		var req request
+		_ = json.Unmarshal(body, &req)
+		resp, _ := json.Marshal(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+			"error":   map[string]interface{}{"code": -100, "message": "Unknown contract"},
+		})
+		_, _ = w.Write(resp)
+	})
+	defer s.Close()
+
+	c := newTestClient(t, Options{Retries: 3, Backoff: time.Millisecond}, s.URL)
+	var res string
+	err := c.Call(context.Background(), "test", []interface{}{}, &res)
+	var rerr *Error
+	if !errors.As(err, &rerr) || rerr.Code != -100 || rerr.Message != "Unknown contract" {
+		t.Fatalf("expected Unknown contract (-100), got %v", err)
+	}
+	if s.Calls() != 1 {
+		t.Errorf("expected 1 call, got %d", s.Calls())
+	}
+}
+
+func TestClientBatch(t *testing.T) {
+	s := newTestServer(t, func(_ int, w http.ResponseWriter, body []byte) {
+		var reqs []request
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			t.Errorf("batch expected: %v", err)
+			return
+		}
+		// Replies come in reverse order and the last request isn't replied
+		// to at all.
+		var resps []map[string]interface{}
+		for i := len(reqs) - 2; i >= 0; i-- {
+			resps = append(resps, map[string]interface{}{
+				"jsonrpc": "2.0",
+				"id":      reqs[i].ID,
+				"result":  reqs[i].Method,
+			})
+		}
+		resp, _ := json.Marshal(resps)
+		_, _ = w.Write(resp)
+	})
+	defer s.Close()
+
+	c := newTestClient(t, Options{}, s.URL)
+	var a, b, d string
+	reqs := []*Request{
+		{Method: "a", Params: []interface{}{}, Result: &a},
+		{Method: "b", Params: []interface{}{}, Result: &b},
+		{Method: "d", Params: []interface{}{}, Result: &d},
+	}
+	if err := c.Batch(context.Background(), reqs); err != nil {
+		t.Fatal(err)
+	}
+	if reqs[0].Err != nil || a != "a" || reqs[1].Err != nil || b != "b" {
+		t.Errorf("expected a and b, got %q (%v) and %q (%v)", a, reqs[0].Err, b, reqs[1].Err)
+	}
+	if !errors.Is(reqs[2].Err, ErrNoResult) {
+		t.Errorf("expected ErrNoResult for the missing id, got %v", reqs[2].Err)
+	}
+}
+
+func TestClientNoResult(t *testing.T) {
+	s := newTestServer(t, func(_ int, w http.ResponseWriter, body []byte) {
+		var req request
+		_ = json.Unmarshal(body, &req)
+		resp, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID})
+		_, _ = w.Write(resp)
+	})
+	defer s.Close()
+
+	c := newTestClient(t, Options{}, s.URL)
+	var res string
+	if err := c.Call(context.Background(), "test", []interface{}{}, &res); !errors.Is(err, ErrNoResult) {
+		t.Fatalf("expected ErrNoResult, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Package rpc implements a client for the custom JSON-RPC dialect of the chain
+data node the simulator reads state from.
+
+All methods take a JSON object of named parameters. Hashes are hex-encoded
+without 0x prefix, BE (as Uint160.StringBE and Uint256.StringBE print them)
+unless stated otherwise. Binary results are hex-encoded strings of the C# node
+serialization, an empty string means there is no such entity.
+
+Methods used by the simulator from the start:
+
+	GetCurrentBlockHeightInUint64 {}
+		number, the current height.
+	GetBlockHashByBlockHeightInHex {BlockHeight}
+		BE hash of the block.
+	GetBlockByBlockHashInHex {BlockHash}
+		serialized block.Block.
+	GetHeaderByBlockHashInHex {BlockHash}
+	GetHeaderByBlockHeightInHex {BlockHeight}
+		serialized block.Header.
+	Data.GetTransactionByHashInHex {Hash}
+		Hash is LE (as Uint256.StringLE prints it), the result is either
+		serialized transaction.Transaction or its JSON. The height of the
+		transaction is not returned.
+	GetContractByContractHashBlockHeightInHex {ContractHash, BlockHeight}
+		storage prefix byte followed by serialized state.Contract, the way
+		C# node keeps it in its DB.
+	GetStorageByContractHashHexKeyBlockHeightInHex {ContractHash, HexKey, BlockHeight}
+		bare value of the storage item, without its flags.
+
+Extensions the simulator relies on for storage item flags, Storage.Find,
+accounts, assets and validators. Their formats are what this package
+expects, they are yet to be confirmed by the node maintainers, so nodes
+without them make the respective interops fail:
+
+	GetStorageItemByContractHashHexKeyBlockHeightInHex {ContractHash, HexKey, BlockHeight}
+		C# StorageItem serialization: version byte, var-length value,
+		constant flag (1 byte). Nodes without it are asked for the bare
+		value, so constant items aren't protected then.
+	FindStorageByContractHashHexPrefixBlockHeightInHex {ContractHash, HexPrefix, BlockHeight}
+		object mapping hex-encoded keys to hex-encoded bare values of all
+		items with the given key prefix.
+	GetAccountByAccountHashBlockHeightInHex {AccountHash, BlockHeight}
+		C# AccountState serialization: version byte, script hash (20
+		bytes), frozen flag (1 byte), var-length array of 33-byte
+		compressed vote keys, var-length array of (asset ID (32 bytes),
+		Fixed8 balance (8 bytes LE)) pairs.
+	GetAssetByAssetHashBlockHeightInHex {AssetHash, BlockHeight}
+		C# AssetState serialization: version byte, asset ID (32 bytes),
+		type byte, var-length string name, Fixed8 amount and available,
+		precision byte, fee mode byte, Fixed8 fee, fee address (20
+		bytes), compressed owner key (33 bytes), admin and issuer (20
+		bytes each), uint32 LE expiration, frozen flag (1 byte).
+	GetValidatorsByBlockHeightInHex {BlockHeight}
+		array of hex-encoded compressed public keys of the validators.
+*/
+package rpc
@@ -0,0 +1,110 @@
+package rpc
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/nspcc-dev/neo-go/pkg/util"
+)
+
+// GetCurrentBlockHeight returns the current height of the chain.
+func (c *Client) GetCurrentBlockHeight(ctx context.Context) (uint32, error) {
+	var height uint32
+	err := c.Call(ctx, "GetCurrentBlockHeightInUint64", map[string]interface{}{}, &height)
+	return height, err
+}
+
+// GetBlockHashByHeight returns the BE hex-encoded hash of the block with the
+// given index.
+func (c *Client) GetBlockHashByHeight(ctx context.Context, height uint32) (string, error) {
+	var hash string
+	err := c.Call(ctx, "GetBlockHashByBlockHeightInHex", map[string]interface{}{"BlockHeight": height}, &hash)
+	return hash, err
+}
+
+// GetBlockByHash returns the serialized block with the given hash.
+func (c *Client) GetBlockByHash(ctx context.Context, hash util.Uint256) ([]byte, error) {
+	return c.callHex(ctx, "GetBlockByBlockHashInHex", map[string]interface{}{"BlockHash": hash.StringBE()})
+}
+
+// GetHeaderByHash returns the serialized header of the block with the given
+// hash.
+func (c *Client) GetHeaderByHash(ctx context.Context, hash util.Uint256) ([]byte, error) {
+	return c.callHex(ctx, "GetHeaderByBlockHashInHex", map[string]interface{}{"BlockHash": hash.StringBE()})
+}
+
+// GetHeaderByHeight returns the serialized header of the block with the given
+// index.
+func (c *Client) GetHeaderByHeight(ctx context.Context, height uint32) ([]byte, error) {
+	return c.callHex(ctx, "GetHeaderByBlockHeightInHex", map[string]interface{}{"BlockHeight": height})
+}
+
+// GetTransaction returns the transaction with the given hash either as a
+// hex-encoded string or as a JSON object.
+func (c *Client) GetTransaction(ctx context.Context, hash util.Uint256) (json.RawMessage, error) {
+	var raw json.RawMessage
+	err := c.Call(ctx, "Data.GetTransactionByHashInHex", map[string]interface{}{"Hash": hash.StringLE()}, &raw)
+	return raw, err
+}
+
+// GetContract returns the serialized state of the contract at the given
+// height, empty if there is no such contract.
+func (c *Client) GetContract(ctx context.Context, hash util.Uint160, height uint32) ([]byte, error) {
+	return c.callHex(ctx, "GetContractByContractHashBlockHeightInHex", map[string]interface{}{"ContractHash": hash.StringBE(), "BlockHeight": height})
+}
+
+// GetStorage returns the value stored by the contract under the given key at
+// the given height, empty if there is none.
+func (c *Client) GetStorage(ctx context.Context, hash util.Uint160, key []byte, height uint32) ([]byte, error) {
+	return c.callHex(ctx, "GetStorageByContractHashHexKeyBlockHeightInHex", map[string]interface{}{"ContractHash": hash.StringBE(), "HexKey": hex.EncodeToString(key), "BlockHeight": height})
+}
+
+// GetStorageItem returns the serialized storage item stored by the contract
+// under the given key at the given height, empty if there is none. Unlike
+// GetStorage it includes item flags. It's a dialect extension, see package
+// documentation.
+func (c *Client) GetStorageItem(ctx context.Context, hash util.Uint160, key []byte, height uint32) ([]byte, error) {
+	return c.callHex(ctx, "GetStorageItemByContractHashHexKeyBlockHeightInHex", map[string]interface{}{"ContractHash": hash.StringBE(), "HexKey": hex.EncodeToString(key), "BlockHeight": height})
+}
+
+// FindStorage returns hex-encoded items stored by the contract under keys with
+// the given prefix at the given height.
+// It's a dialect extension, see package documentation.
+func (c *Client) FindStorage(ctx context.Context, hash util.Uint160, prefix []byte, height uint32) (map[string]string, error) {
+	var items map[string]string
+	err := c.Call(ctx, "FindStorageByContractHashHexPrefixBlockHeightInHex", map[string]interface{}{"ContractHash": hash.StringBE(), "HexPrefix": hex.EncodeToString(prefix), "BlockHeight": height}, &items)
+	return items, err
+}
+
+// GetAccount returns the serialized state of the account at the given height,
+// empty if there is no such account.
+// It's a dialect extension, see package documentation.
+func (c *Client) GetAccount(ctx context.Context, hash util.Uint160, height uint32) ([]byte, error) {
+	return c.callHex(ctx, "GetAccountByAccountHashBlockHeightInHex", map[string]interface{}{"AccountHash": hash.StringBE(), "BlockHeight": height})
+}
+
+// GetAsset returns the serialized state of the asset at the given height,
+// empty if there is no such asset.
+// It's a dialect extension, see package documentation.
+func (c *Client) GetAsset(ctx context.Context, id util.Uint256, height uint32) ([]byte, error) {
+	return c.callHex(ctx, "GetAssetByAssetHashBlockHeightInHex", map[string]interface{}{"AssetHash": id.StringBE(), "BlockHeight": height})
+}
+
+// GetValidators returns hex-encoded public keys of the validators at the given
+// height.
+// It's a dialect extension, see package documentation.
+func (c *Client) GetValidators(ctx context.Context, height uint32) ([]string, error) {
+	var keys []string
+	err := c.Call(ctx, "GetValidatorsByBlockHeightInHex", map[string]interface{}{"BlockHeight": height}, &keys)
+	return keys, err
+}
+
+// callHex performs a request returning hex-encoded string and decodes it.
+func (c *Client) callHex(ctx context.Context, method string, params interface{}) ([]byte, error) {
+	var str string
+	if err := c.Call(ctx, method, params, &str); err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(str)
+}
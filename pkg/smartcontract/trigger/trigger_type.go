@@ -1,5 +1,7 @@
 package trigger
 
+import "fmt"
+
 //go:generate stringer -type=Type
 
 // Type represents trigger type used in C# reference node: https://github.com/neo-project/neo/blob/c64748ecbac3baeb8045b16af0d518398a6ced24/neo/SmartContract/TriggerType.cs#L3
@@ -39,3 +41,14 @@ const (
 	// The received function will be invoked automatically when a contract is receiving assets from a transfer.
 	ApplicationR Type = 0x11
 )
+
+// FromString converts string to trigger Type.
+func FromString(str string) (Type, error) {
+	triggers := []Type{Verification, VerificationR, Application, ApplicationR}
+	for _, t := range triggers {
+		if t.String() == str {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown trigger type: %s", str)
+}
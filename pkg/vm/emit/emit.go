@@ -48,22 +48,18 @@ func Int(w *io.BinWriter, i int64) {
 	}
 }
 
-// Array emits array of elements to the given buffer.
+// MapEntry is a key-value pair of a map emitted by Map.
+type MapEntry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// Array emits array of elements to the given buffer. Elements can be nested
+// arrays ([]interface{}) and maps ([]MapEntry).
 func Array(w *io.BinWriter, es ...interface{}) {
 	for i := len(es) - 1; i >= 0; i-- {
-		switch e := es[i].(type) {
-		case int64:
-			Int(w, e)
-		case string:
-			String(w, e)
-		case util.Uint160:
-			Bytes(w, e.BytesBE())
-		case []byte:
-			Bytes(w, e)
-		case bool:
-			Bool(w, e)
-		default:
-			w.Err = errors.New("unsupported type")
+		element(w, es[i])
+		if w.Err != nil {
 			return
 		}
 	}
@@ -71,6 +67,44 @@ func Array(w *io.BinWriter, es ...interface{}) {
 	Opcode(w, opcode.PACK)
 }
 
+// Map emits a map with the given entries to the given buffer.
+func Map(w *io.BinWriter, es []MapEntry) {
+	Opcode(w, opcode.NEWMAP)
+	for _, e := range es {
+		Opcode(w, opcode.DUP)
+		element(w, e.Key)
+		element(w, e.Value)
+		Opcode(w, opcode.SETITEM)
+		if w.Err != nil {
+			return
+		}
+	}
+}
+
+// element emits a single array or map element to the given buffer.
+func element(w *io.BinWriter, e interface{}) {
+	switch e := e.(type) {
+	case int64:
+		Int(w, e)
+	case string:
+		String(w, e)
+	case util.Uint160:
+		Bytes(w, e.BytesBE())
+	case util.Uint256:
+		Bytes(w, e.BytesBE())
+	case []byte:
+		Bytes(w, e)
+	case bool:
+		Bool(w, e)
+	case []interface{}:
+		Array(w, e...)
+	case []MapEntry:
+		Map(w, e)
+	default:
+		w.Err = errors.New("unsupported type")
+	}
+}
+
 // String emits a string to the given buffer.
 func String(w *io.BinWriter, s string) {
 	Bytes(w, []byte(s))
@@ -28,6 +28,28 @@ func (e *errorAtInstruct) Error() string {
 	return fmt.Sprintf("error encountered at instruction %d (%s): %s", e.ip, e.op, e.err)
 }
 
+// Unwrap returns the underlying error if there is one.
+func (e *errorAtInstruct) Unwrap() error {
+	err, _ := e.err.(error)
+	return err
+}
+
+// SyscallError is an error returned by an interop function.
+type SyscallError struct {
+	// Name is the interop name (or its ID) the script has called.
+	Name string
+	Err  error
+}
+
+func (e *SyscallError) Error() string {
+	return fmt.Sprintf("failed to invoke syscall: %s", e.Err)
+}
+
+// Unwrap returns the interop function error.
+func (e *SyscallError) Unwrap() error {
+	return e.Err
+}
+
 func newError(ip int, op opcode.Opcode, err interface{}) *errorAtInstruct {
 	return &errorAtInstruct{ip: ip, op: op, err: err}
 }
@@ -67,7 +89,7 @@ type VM struct {
 	getPrice func(*VM, opcode.Opcode, []byte) util.Fixed8
 
 	// callback to get scripts.
-	getScript func(util.Uint160) ([]byte, bool)
+	getScript func(util.Uint160) ([]byte, bool, error)
 
 	istack *Stack // invocation stack.
 	estack *Stack // execution stack.
@@ -473,8 +495,9 @@ func (v *VM) SetCheckedHash(h []byte) {
 	copy(v.checkhash, h)
 }
 
-// SetScriptGetter sets the script getter for CALL instructions.
-func (v *VM) SetScriptGetter(gs func(util.Uint160) ([]byte, bool)) {
+// SetScriptGetter sets the script getter for CALL instructions. An error
+// returned by the getter faults the VM.
+func (v *VM) SetScriptGetter(gs func(util.Uint160) ([]byte, bool, error)) {
 	v.getScript = gs
 }
 
@@ -1154,7 +1177,11 @@ func (v *VM) execute(ctx *Context, op opcode.Opcode, parameter []byte) (err erro
 			panic(fmt.Sprintf("interop hook (%q/0x%x) not registered", parameter, interopID))
 		}
 		if err := ifunc.Func(v); err != nil {
-			panic(fmt.Sprintf("failed to invoke syscall: %s", err))
+			name := string(parameter)
+			if len(parameter) == 4 {
+				name = fmt.Sprintf("0x%08x", interopID)
+			}
+			panic(&SyscallError{Name: name, Err: err})
 		}
 
 	case opcode.APPCALL, opcode.TAILCALL:
@@ -1171,7 +1198,10 @@ func (v *VM) execute(ctx *Context, op opcode.Opcode, parameter []byte) (err erro
 			panic(err)
 		}
 
-		script, hasDynamicInvoke := v.getScript(hash)
+		script, hasDynamicInvoke, err := v.getScript(hash)
+		if err != nil {
+			panic(err)
+		}
 		if script == nil {
 			panic("could not find script")
 		}
@@ -1382,7 +1412,10 @@ func (v *VM) execute(ctx *Context, op opcode.Opcode, parameter []byte) (err erro
 			if err != nil {
 				panic(err)
 			}
-			script, hasDynamicInvoke := v.getScript(hash)
+			script, hasDynamicInvoke, err := v.getScript(hash)
+			if err != nil {
+				panic(err)
+			}
 			if script == nil {
 				panic(fmt.Sprintf("could not find script %s", hash))
 			}